@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/forms"
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Handlers for the /api/v1/snippets resource. Requests and responses are
+// JSON; validation reuses the rules of the HTML forms so both front ends
+// accept exactly the same snippets.
+
+// snippetJSON is the API representation of a snippet. It is kept separate
+// from models.Snippet so the field names clients see don't change when the
+// model does.
+type snippetJSON struct {
+	ID      int       `json:"id"`
+	UserID  int       `json:"user_id,omitempty"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Updated time.Time `json:"updated"`
+
+	Visibility string `json:"visibility"`
+	Slug       string `json:"slug,omitempty"` // only for unlisted and private snippets, which are fetched by it
+	Protected  bool   `json:"protected"`      // reading it needs the password
+}
+
+func newSnippetJSON(s *models.Snippet) *snippetJSON {
+	tags := s.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	sj := &snippetJSON{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Tags:       tags,
+		Created:    s.Created,
+		Expires:    s.Expires,
+		Updated:    s.Updated,
+		Visibility: s.Visibility,
+		Protected:  s.Protected(),
+	}
+	if s.Visibility != models.VisibilityPublic {
+		sj.Slug = s.Slug
+	}
+	return sj
+}
+
+// newListedSnippetJSON is newSnippetJSON for listings, which leave out the
+// content of password-protected snippets.
+func newListedSnippetJSON(s *models.Snippet) *snippetJSON {
+	sj := newSnippetJSON(s)
+	if s.Protected() {
+		sj.Content = ""
+	}
+	return sj
+}
+
+// snippetListJSON is one page of GET /api/v1/snippets.
+type snippetListJSON struct {
+	Snippets []*snippetJSON `json:"snippets"`
+	Next     string         `json:"next,omitempty"` // cursor for the next page
+	Prev     string         `json:"prev,omitempty"` // cursor for the previous page
+}
+
+// searchResultJSON is a snippet matched by GET /api/v1/search. The headline
+// is an HTML fragment with each hit wrapped in <mark>, exactly as the /search
+// page shows it.
+type searchResultJSON struct {
+	*snippetJSON
+	Rank     float64       `json:"rank"`
+	Headline template.HTML `json:"headline"`
+}
+
+type searchPageJSON struct {
+	Query   string              `json:"query"`
+	Page    int                 `json:"page"`
+	More    bool                `json:"more"`
+	Results []*searchResultJSON `json:"results"`
+}
+
+type tagJSON struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// snippetInput is the request body for POST, PUT and PATCH. Fields are
+// pointers so a PATCH can tell an omitted field from an empty one.
+type snippetInput struct {
+	Title       *string   `json:"title"`
+	Content     *string   `json:"content"`
+	Tags        *[]string `json:"tags"`
+	ExpiresDays *int      `json:"expires_days"` // 1, 7 or 365, as on the create form
+	Visibility  *string   `json:"visibility"`   // public (the default), unlisted or private
+	Password    *string   `json:"password"`     // needed to read the snippet; only on create
+}
+
+// form converts the input to the url.Values the HTML forms post, so it can
+// go through validateSnippetForm. Omitted fields are left unset.
+func (in *snippetInput) form() *forms.Form {
+	v := url.Values{}
+	if in.Title != nil {
+		v.Set("title", *in.Title)
+	}
+	if in.Content != nil {
+		v.Set("content", *in.Content)
+	}
+	if in.Tags != nil {
+		v.Set("tags", strings.Join(*in.Tags, ","))
+	}
+	if in.ExpiresDays != nil {
+		v.Set("expires", strconv.Itoa(*in.ExpiresDays))
+	}
+	if in.Visibility != nil {
+		v.Set("visibility", *in.Visibility)
+	}
+	if in.Password != nil {
+		v.Set("password", *in.Password)
+	}
+	return forms.New(v)
+}
+
+// apiFieldNames maps form field names to the JSON fields they came from,
+// where the two differ.
+var apiFieldNames = map[string]string{
+	"expires": "expires_days",
+}
+
+// maxAPIBodyBytes caps the size of JSON request bodies.
+const maxAPIBodyBytes = 1 << 20
+
+// writeJSON sends v as indented JSON with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	js, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// readJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and anything after the object.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("body must not be empty")
+		}
+		return err
+	}
+
+	if dec.More() {
+		return errors.New("body must contain a single JSON object")
+	}
+
+	return nil
+}
+
+// apiSnippet loads the snippet named by the :id URL parameter, an ID or a
+// slug, writing a JSON error and returning nil if there isn't one. A
+// password-protected snippet needs its password in the X-Snippet-Password
+// header. With forUpdate set it also checks that the current user may change
+// it and that the request's preconditions, if any, hold.
+func (app *application) apiSnippet(w http.ResponseWriter, r *http.Request, forUpdate bool) *models.Snippet {
+	s, err := app.findSnippet(r, r.URL.Query().Get(":id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return nil
+	}
+
+	if forUpdate && !app.canModify(r, s) {
+		app.apiProblem(w, r, http.StatusForbidden, codeForbidden, "You may not modify this snippet.")
+		return nil
+	}
+
+	if err := app.passwordAccess(r, s); err != nil {
+		app.lockedAs(w, r, formatJSON, s, err)
+		return nil
+	}
+
+	if forUpdate && app.preconditionFailed(w, r, s) {
+		return nil
+	}
+
+	return s
+}
+
+// API Usage: curl -i 'http://localhost:4000/api/v1/snippets?sort=title&order=asc&limit=10'
+
+func (app *application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	opts, form := listOptions(r.URL.Query())
+	if !form.Valid() {
+		app.apiValidationError(w, r, form)
+		return
+	}
+
+	page, err := app.snippets.List(opts)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.apiProblem(w, r, http.StatusBadRequest, codeInvalidCursor, "The cursor is malformed or belongs to a different listing.")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	snippets := make([]*snippetJSON, 0, len(page.Snippets))
+	for _, s := range page.Snippets {
+		snippets = append(snippets, newListedSnippetJSON(s))
+	}
+
+	app.writeJSON(w, http.StatusOK, &snippetListJSON{snippets, page.Next, page.Prev})
+}
+
+// API Usage: curl -i localhost:4000/api/v1/snippets/1
+
+func (app *application) apiGetSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiSnippet(w, r, false)
+	if s == nil {
+		return
+	}
+
+	if notModified(w, r, s, snippetETag(s, formatJSON), false) {
+		return
+	}
+	app.writeJSON(w, http.StatusOK, newSnippetJSON(s))
+}
+
+// API Usage:
+// curl -i -H 'Authorization: Bearer sbx_...' -X POST localhost:4000/api/v1/snippets \
+//   -d '{"title": "O snail", "content": "Climb Mount Fuji", "tags": ["haiku"], "expires_days": 7}'
+
+func (app *application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	var in snippetInput
+	if err := app.readJSON(w, r, &in); err != nil {
+		app.apiProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "Invalid request body: "+err.Error())
+		return
+	}
+
+	form := in.form()
+	tags := validateSnippetForm(form)
+	validateSnippetPassword(form)
+	if !form.Valid() {
+		app.apiValidationError(w, r, form)
+		return
+	}
+
+	id, err := app.snippets.Insert(
+		app.authenticatedUser(r).ID,
+		form.Get("title"),
+		form.Get("content"),
+		form.Get("expires"),
+		form.Get("visibility"),
+		form.Get("password"),
+		tags,
+	)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/snippets/"+s.Ref())
+	w.Header().Set("ETag", snippetETag(s, formatJSON))
+	app.writeJSON(w, http.StatusCreated, newSnippetJSON(s))
+}
+
+// apiUpdateSnippet handles both PUT, which replaces the snippet and so needs
+// every field, and PATCH, which only changes the fields present. A PATCH
+// without expires_days leaves the expiry alone.
+func (app *application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiSnippet(w, r, true)
+	if s == nil {
+		return
+	}
+
+	var in snippetInput
+	if err := app.readJSON(w, r, &in); err != nil {
+		app.apiProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "Invalid request body: "+err.Error())
+		return
+	}
+
+	var tags []string
+	var form *forms.Form
+
+	if r.Method == http.MethodPatch {
+		if in.Title == nil {
+			in.Title = &s.Title
+		}
+		if in.Content == nil {
+			in.Content = &s.Content
+		}
+		if in.Tags == nil {
+			in.Tags = &s.Tags
+		}
+		form = in.form()
+		form.Required("title", "content")
+		tags = validateSnippetFields(form)
+	} else {
+		form = in.form()
+		tags = validateSnippetForm(form)
+	}
+
+	if in.Password != nil {
+		form.Errors.Add("password", "The password can only be set when the snippet is created")
+	}
+
+	if !form.Valid() {
+		app.apiValidationError(w, r, form)
+		return
+	}
+
+	err := app.snippets.Update(
+		s.ID,
+		app.authenticatedUser(r).ID,
+		form.Get("title"),
+		form.Get("content"),
+		form.Get("expires"),
+		form.Get("visibility"),
+		tags,
+	)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	s, err = app.snippets.Get(s.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", snippetETag(s, formatJSON))
+	app.writeJSON(w, http.StatusOK, newSnippetJSON(s))
+}
+
+func (app *application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiSnippet(w, r, true)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// insertAPIUser signs up a user and returns a write token for them.
+func insertAPIUser(t *testing.T, app *application, name string) string {
+	t.Helper()
+
+	if err := app.users.Insert(name, strings.ToLower(name)+"@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := app.users.Authenticate(strings.ToLower(name)+"@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.tokens.Insert(u, "test", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// apiHeader returns the headers of a JSON API request made with token.
+func apiHeader(token string) http.Header {
+	return http.Header{
+		"Authorization": {"Bearer " + token},
+		"Content-Type":  {"application/json"},
+	}
+}
+
+// problemCode returns the code of a problem details body.
+func problemCode(t *testing.T, body string) string {
+	t.Helper()
+
+	var p struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatalf("the body isn't a problem: %v: %s", err, body)
+	}
+	return p.Code
+}
+
+func TestAPICreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	token := insertAPIUser(t, app, "Alice")
+
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantProblem string
+	}{
+		{"Valid", `{"title": "Title", "content": "Content", "tags": ["Go"], "expires_days": 7}`, http.StatusCreated, ""},
+		{"Unknown field", `{"title": "Title", "content": "Content", "expires_days": 7, "author": "Bob"}`, http.StatusBadRequest, codeInvalidJSON},
+		{"Wrong type", `{"title": "Title", "content": "Content", "expires_days": "7"}`, http.StatusBadRequest, codeInvalidJSON},
+		{"Empty body", "", http.StatusBadRequest, codeInvalidJSON},
+		{"Two objects", `{"title": "Title"} {"content": "Content"}`, http.StatusBadRequest, codeInvalidJSON},
+		{"Over 1MB", `{"title": "Title", "content": "` + strings.Repeat("x", maxAPIBodyBytes) + `", "expires_days": 7}`, http.StatusBadRequest, codeInvalidJSON},
+		{"Missing fields", `{"title": "Title"}`, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"Bad expiry", `{"title": "Title", "content": "Content", "expires_days": 3}`, http.StatusUnprocessableEntity, codeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodPost, "/api/v1/snippets", apiHeader(token), tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d: %s", code, tt.wantCode, body)
+			}
+			if tt.wantProblem != "" {
+				if got := problemCode(t, body); got != tt.wantProblem {
+					t.Errorf("got problem %q; want %q", got, tt.wantProblem)
+				}
+				return
+			}
+
+			var s snippetJSON
+			if err := json.Unmarshal([]byte(body), &s); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := header.Get("Location"), "/api/v1/snippets/1"; got != want {
+				t.Errorf("got Location %q; want %q", got, want)
+			}
+			if header.Get("ETag") == "" {
+				t.Error("no ETag")
+			}
+			if s.Title != "Title" || s.Content != "Content" || len(s.Tags) != 1 || s.Tags[0] != "go" {
+				t.Errorf("got %+v", s)
+			}
+		})
+	}
+}
+
+func TestAPIUpdateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	alice := insertAPIUser(t, app, "Alice")
+	bob := insertAPIUser(t, app, "Bob")
+
+	code, _, body := ts.do(t, http.MethodPost, "/api/v1/snippets", apiHeader(alice),
+		`{"title": "Title", "content": "Content", "tags": ["go"], "expires_days": 7}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", code, body)
+	}
+
+	// etag returns the snippet's current JSON ETag.
+	etag := func() string {
+		t.Helper()
+		_, header, _ := ts.get(t, "/api/v1/snippets/1")
+		return header.Get("ETag")
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		ifMatch     string
+		body        string
+		wantCode    int
+		wantProblem string
+		want        snippetJSON // checked on success
+	}{
+		{
+			name: "PUT needs every field", method: http.MethodPut, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"title": "New title"}`,
+			wantCode: http.StatusUnprocessableEntity, wantProblem: codeValidationFailed,
+		},
+		{
+			name: "PUT", method: http.MethodPut, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"title": "New title", "content": "New content", "expires_days": 7}`,
+			wantCode: http.StatusOK, want: snippetJSON{Title: "New title", Content: "New content", Tags: []string{}},
+		},
+		{
+			name: "PATCH keeps omitted fields", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"tags": ["db"]}`,
+			wantCode: http.StatusOK, want: snippetJSON{Title: "New title", Content: "New content", Tags: []string{"db"}},
+		},
+		{
+			name: "PATCH can't empty a field", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"title": ""}`,
+			wantCode: http.StatusUnprocessableEntity, wantProblem: codeValidationFailed,
+		},
+		{
+			name: "PATCH can't set a password", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"password": "secret"}`,
+			wantCode: http.StatusUnprocessableEntity, wantProblem: codeValidationFailed,
+		},
+		{
+			name: "PATCH with an unknown field", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice,
+			body:     `{"titel": "Typo"}`,
+			wantCode: http.StatusBadRequest, wantProblem: codeInvalidJSON,
+		},
+		{
+			name: "Another user's snippet", method: http.MethodPatch, path: "/api/v1/snippets/1", token: bob,
+			body:     `{"title": "Mine now"}`,
+			wantCode: http.StatusForbidden, wantProblem: codeForbidden,
+		},
+		{
+			name: "Another user deleting", method: http.MethodDelete, path: "/api/v1/snippets/1", token: bob,
+			wantCode: http.StatusForbidden, wantProblem: codeForbidden,
+		},
+		{
+			name: "Missing snippet", method: http.MethodPatch, path: "/api/v1/snippets/99", token: alice,
+			body:     `{"title": "Title"}`,
+			wantCode: http.StatusNotFound, wantProblem: codeNotFound,
+		},
+		{
+			name: "Stale If-Match", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice, ifMatch: `"stale"`,
+			body:     `{"title": "Lost update"}`,
+			wantCode: http.StatusPreconditionFailed, wantProblem: codePreconditionFailed,
+		},
+		{
+			name: "Current If-Match", method: http.MethodPatch, path: "/api/v1/snippets/1", token: alice, ifMatch: "current",
+			body:     `{"title": "Checked update"}`,
+			wantCode: http.StatusOK, want: snippetJSON{Title: "Checked update", Content: "New content", Tags: []string{"db"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := apiHeader(tt.token)
+			switch tt.ifMatch {
+			case "":
+			case "current":
+				header.Set("If-Match", etag())
+			default:
+				header.Set("If-Match", tt.ifMatch)
+			}
+
+			code, header, body := ts.do(t, tt.method, tt.path, header, tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d: %s", code, tt.wantCode, body)
+			}
+			if tt.wantProblem != "" {
+				if got := problemCode(t, body); got != tt.wantProblem {
+					t.Errorf("got problem %q; want %q", got, tt.wantProblem)
+				}
+				return
+			}
+
+			var s snippetJSON
+			if err := json.Unmarshal([]byte(body), &s); err != nil {
+				t.Fatal(err)
+			}
+			if s.Title != tt.want.Title || s.Content != tt.want.Content || strings.Join(s.Tags, ",") != strings.Join(tt.want.Tags, ",") {
+				t.Errorf("got %q %q %v; want %q %q %v", s.Title, s.Content, s.Tags, tt.want.Title, tt.want.Content, tt.want.Tags)
+			}
+			if got := header.Get("ETag"); got != etag() {
+				t.Errorf("got ETag %q; want the snippet's new one", got)
+			}
+		})
+	}
+}
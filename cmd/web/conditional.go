@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Conditional requests for snippets. Each representation of a snippet gets
+// a strong ETag hashed from everything it shows, and Last-Modified is the
+// time of its latest revision, so GETs can be answered with 304 Not Modified
+// and writes through the API can be made conditional with If-Match. Pages
+// that differ between users get no Last-Modified: logging in or out changes
+// them without changing the snippet, which only the ETag notices.
+
+// snippetMaxAge caps how long a shared cache may reuse a snippet without
+// revalidating. Snippets can be edited, so it is kept short; revalidation is
+// cheap since it usually ends in a 304.
+const snippetMaxAge = time.Minute
+
+// snippetETag returns the entity tag of one representation of s. variant
+// names the representation, including anything besides s that it depends
+// on, such as who is looking at an HTML page.
+func snippetETag(s *models.Snippet, variant string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00%s\x00%s\x00%s\x00%d\x00%d\x00%d\x00%s\x00%s\x00%t", variant,
+		s.ID, s.UserID, s.Title, s.Content, strings.Join(s.Tags, ","),
+		s.Created.UnixNano(), s.Expires.UnixNano(), s.Updated.UnixNano(), s.Visibility, s.Slug, s.Protected())
+	return `"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:18]) + `"`
+}
+
+// setValidators sets ETag, Last-Modified and Cache-Control for a response
+// showing s. private marks representations that differ between users, which
+// only the user's own browser may cache and which are validated by their
+// ETag alone. Snippets that aren't public, or need a password, are never
+// left in shared caches either.
+func setValidators(w http.ResponseWriter, s *models.Snippet, etag string, private bool) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !private {
+		h.Set("Last-Modified", s.Updated.UTC().Format(http.TimeFormat))
+	}
+
+	if private || s.Visibility != models.VisibilityPublic || s.Protected() {
+		h.Set("Cache-Control", "private, no-cache")
+	} else {
+		// Never let a cache serve a snippet past its expiry, when it
+		// disappears.
+		maxAge := time.Until(s.Expires)
+		if maxAge > snippetMaxAge {
+			maxAge = snippetMaxAge
+		}
+		if maxAge < 0 {
+			maxAge = 0
+		}
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	}
+}
+
+// notModified calls setValidators and then, if the client's copy is still
+// current, answers 304 and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, s *models.Snippet, etag string, private bool) bool {
+	setValidators(w, s, etag, private)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence; If-Modified-Since is only consulted
+	// without it (RFC 7232, section 6), and only if we sent Last-Modified.
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, etag, false) {
+			return false
+		}
+	} else if private {
+		return false
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || s.Updated.Truncate(time.Second).After(ims) {
+		return false
+	}
+
+	// A 304 updates the headers of the cached response, so it mustn't
+	// carry this request's CSP nonce: the cached page has the old one.
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Security-Policy")
+	w.Header().Del("Content-Security-Policy-Report-Only")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed checks If-Match and If-Unmodified-Since on a request
+// that changes s, answering 412 and returning true if the client's copy is
+// out of date. The tags compared are those of the API representation.
+func (app *application) preconditionFailed(w http.ResponseWriter, r *http.Request, s *models.Snippet) bool {
+	etag := snippetETag(s, formatJSON)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if etagListMatches(im, etag, true) {
+			return false
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err != nil || !s.Updated.Truncate(time.Second).After(ius) {
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+	app.apiProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed,
+		"The snippet has changed since you fetched it. Fetch it again and retry.")
+	return true
+}
+
+// etagListMatches reports whether etag is in the comma-separated list of an
+// If-Match or If-None-Match header, or the list is "*". If-Match uses the
+// strong comparison, which weak tags never pass; If-None-Match the weak one.
+func etagListMatches(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestEtagListMatches(t *testing.T) {
+	const etag = `"abc"`
+
+	tests := []struct {
+		name       string
+		list       string
+		wantWeak   bool
+		wantStrong bool
+	}{
+		{"Same", `"abc"`, true, true},
+		{"Different", `"abd"`, false, false},
+		{"In a list", `"x", "abc", "y"`, true, true},
+		{"List without spaces", `"x","abc"`, true, true},
+		{"Weak", `W/"abc"`, true, false},
+		{"Weak in a list", `"x", W/"abc"`, true, false},
+		{"Weak and strong", `W/"abc", "abc"`, true, true},
+		{"Star", `*`, true, true},
+		{"Star with spaces", ` * `, true, true},
+		{"Star in a list", `"x", *`, false, false},
+		{"Unquoted", `abc`, false, false},
+		{"Lowercase weak prefix", `w/"abc"`, false, false},
+		{"Empty", ``, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagListMatches(tt.list, etag, false); got != tt.wantWeak {
+				t.Errorf("weak comparison: got %t; want %t", got, tt.wantWeak)
+			}
+			if got := etagListMatches(tt.list, etag, true); got != tt.wantStrong {
+				t.Errorf("strong comparison: got %t; want %t", got, tt.wantStrong)
+			}
+		})
+	}
+}
+
+func newConditionalSnippet() *models.Snippet {
+	updated := time.Date(2021, 3, 4, 5, 6, 7, 500, time.UTC)
+	return &models.Snippet{
+		ID:         1,
+		Title:      "Title",
+		Content:    "Content",
+		Created:    updated.Add(-time.Hour),
+		Updated:    updated,
+		Expires:    time.Now().Add(time.Hour),
+		Visibility: models.VisibilityPublic,
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	s := newConditionalSnippet()
+	etag := snippetETag(s, formatJSON)
+
+	updated := s.Updated.Format(http.TimeFormat)
+	earlier := s.Updated.Add(-time.Second).Format(http.TimeFormat)
+	later := s.Updated.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		method  string
+		header  http.Header
+		private bool
+		want    bool
+	}{
+		{"No conditions", http.MethodGet, nil, false, false},
+		{"ETag matches", http.MethodGet, http.Header{"If-None-Match": {etag}}, false, true},
+		{"Weak ETag matches", http.MethodGet, http.Header{"If-None-Match": {"W/" + etag}}, false, true},
+		{"ETag in a list", http.MethodGet, http.Header{"If-None-Match": {`"old", ` + etag}}, false, true},
+		{"Other ETag", http.MethodGet, http.Header{"If-None-Match": {`"old"`}}, false, false},
+		{"Star", http.MethodGet, http.Header{"If-None-Match": {"*"}}, false, true},
+		{"HEAD", http.MethodHead, http.Header{"If-None-Match": {etag}}, false, true},
+		{"POST", http.MethodPost, http.Header{"If-None-Match": {etag}}, false, false},
+		{"Not modified since", http.MethodGet, http.Header{"If-Modified-Since": {updated}}, false, true},
+		{"Not modified since later", http.MethodGet, http.Header{"If-Modified-Since": {later}}, false, true},
+		{"Modified since", http.MethodGet, http.Header{"If-Modified-Since": {earlier}}, false, false},
+		{"Bad date", http.MethodGet, http.Header{"If-Modified-Since": {"yesterday"}}, false, false},
+		{"ETag wins over date", http.MethodGet, http.Header{"If-None-Match": {`"old"`}, "If-Modified-Since": {later}}, false, false},
+		{"Private ETag matches", http.MethodGet, http.Header{"If-None-Match": {etag}}, true, true},
+		{"Private ignores date", http.MethodGet, http.Header{"If-Modified-Since": {later}}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Content-Type", "text/html")
+			rr.Header().Set("Content-Security-Policy", "script-src 'nonce-x'")
+
+			r := httptest.NewRequest(tt.method, "/snippet/1", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			if got := notModified(rr, r, s, etag, tt.private); got != tt.want {
+				t.Fatalf("got %t; want %t", got, tt.want)
+			}
+
+			h := rr.Header()
+			if h.Get("ETag") != etag {
+				t.Errorf("got ETag %q; want %q", h.Get("ETag"), etag)
+			}
+			if lm := h.Get("Last-Modified"); tt.private && lm != "" {
+				t.Errorf("got Last-Modified %q on a private response", lm)
+			} else if !tt.private && lm != updated {
+				t.Errorf("got Last-Modified %q; want %q", lm, updated)
+			}
+
+			if !tt.want {
+				return
+			}
+			if rr.Code != http.StatusNotModified {
+				t.Errorf("got status %d; want %d", rr.Code, http.StatusNotModified)
+			}
+			for _, name := range []string{"Content-Type", "Content-Security-Policy"} {
+				if v := h.Get(name); v != "" {
+					t.Errorf("got %s %q on a 304", name, v)
+				}
+			}
+		})
+	}
+}
+
+func TestSetValidatorsCacheControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*models.Snippet)
+		private bool
+		want    string
+	}{
+		{"Public", func(s *models.Snippet) {}, false, "public, max-age=60"},
+		{"Expiring soon", func(s *models.Snippet) { s.Expires = time.Now().Add(10*time.Second + 500*time.Millisecond) }, false, "public, max-age=10"},
+		{"Expired", func(s *models.Snippet) { s.Expires = time.Now().Add(-time.Second) }, false, "public, max-age=0"},
+		{"Per user", func(s *models.Snippet) {}, true, "private, no-cache"},
+		{"Unlisted", func(s *models.Snippet) { s.Visibility = models.VisibilityUnlisted }, false, "private, no-cache"},
+		{"Protected", func(s *models.Snippet) { s.PasswordHash = []byte("hash") }, false, "private, no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newConditionalSnippet()
+			tt.modify(s)
+
+			rr := httptest.NewRecorder()
+			setValidators(rr, s, snippetETag(s, formatJSON), tt.private)
+			if got := rr.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	app := newTestApplication(t)
+	s := newConditionalSnippet()
+	etag := snippetETag(s, formatJSON)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"No conditions", nil, false},
+		{"ETag matches", http.Header{"If-Match": {etag}}, false},
+		{"ETag in a list", http.Header{"If-Match": {`"old", ` + etag}}, false},
+		{"Star", http.Header{"If-Match": {"*"}}, false},
+		{"Other ETag", http.Header{"If-Match": {`"old"`}}, true},
+		{"Weak ETag", http.Header{"If-Match": {"W/" + etag}}, true},
+		{"HTML ETag", http.Header{"If-Match": {snippetETag(s, formatHTML)}}, true},
+		{"Unmodified since", http.Header{"If-Unmodified-Since": {s.Updated.Format(http.TimeFormat)}}, false},
+		{"Modified since", http.Header{"If-Unmodified-Since": {s.Updated.Add(-time.Second).Format(http.TimeFormat)}}, true},
+		{"Bad date", http.Header{"If-Unmodified-Since": {"yesterday"}}, false},
+		{"ETag wins over date", http.Header{"If-Match": {etag}, "If-Unmodified-Since": {s.Updated.Add(-time.Hour).Format(http.TimeFormat)}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPatch, "/api/v1/snippets/1", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			if got := app.preconditionFailed(rr, r, s); got != tt.want {
+				t.Fatalf("got %t; want %t", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			if rr.Code != http.StatusPreconditionFailed {
+				t.Errorf("got status %d; want %d", rr.Code, http.StatusPreconditionFailed)
+			}
+			if got := rr.Header().Get("ETag"); got != etag {
+				t.Errorf("got ETag %q; want %q", got, etag)
+			}
+		})
+	}
+}
+
+// TestShowSnippetConditional checks that the HTML page, which depends on who
+// is looking, is only revalidated by its ETag.
+func TestShowSnippetConditional(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if _, err := app.snippets.Insert(1, "Title", "Content", "7", models.VisibilityPublic, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	code, header, _ := ts.get(t, "/snippet/1")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", code, http.StatusOK)
+	}
+	if lm := header.Get("Last-Modified"); lm != "" {
+		t.Errorf("got Last-Modified %q on the HTML page", lm)
+	}
+	etag := header.Get("ETag")
+
+	later := http.Header{"If-Modified-Since": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	if code, _, _ := ts.do(t, http.MethodGet, "/snippet/1", later, ""); code != http.StatusOK {
+		t.Errorf("If-Modified-Since: got status %d; want %d", code, http.StatusOK)
+	}
+
+	if code, _, _ := ts.do(t, http.MethodGet, "/snippet/1", http.Header{"If-None-Match": {etag}}, ""); code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got status %d; want %d", code, http.StatusNotModified)
+	}
+
+	// Logging in changes the page, and so its ETag.
+	ts.login(t, app)
+	code, header, _ = ts.do(t, http.MethodGet, "/snippet/1", http.Header{"If-None-Match": {etag}}, "")
+	if code != http.StatusOK {
+		t.Errorf("after login: got status %d; want %d", code, http.StatusOK)
+	}
+	if header.Get("ETag") == etag {
+		t.Error("after login: the ETag didn't change")
+	}
+
+	// The JSON representation is the same for everyone.
+	code, _, _ = ts.do(t, http.MethodGet, "/snippet/1", http.Header{"Accept": {formatJSON}, "If-Modified-Since": later["If-Modified-Since"]}, "")
+	if code != http.StatusNotModified {
+		t.Errorf("JSON If-Modified-Since: got status %d; want %d", code, http.StatusNotModified)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+// Define a custom type for request context keys, so our values can't collide
+// with keys set by other packages using the same plain string.
+type contextKey string
+
+// The *models.User for the current request is stored in the request context
+// under this key by the authenticate middleware.
+const contextKeyUser = contextKey("user")
+
+// API requests authenticated with a bearer token also carry the
+// *models.Token, so handlers can check its scope.
+const contextKeyToken = contextKey("token")
+
+// The Content-Security-Policy nonce generated for the request by
+// securityHeaders.
+const contextKeyNonce = contextKey("nonce")
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cross-origin access to the API, for browser-based tools served from other
+// origins. Nothing is allowed unless Config.CORSOrigins lists the origin, or
+// is "*".
+
+// corsMaxAge is how long browsers may cache a preflight response.
+const corsMaxAge = 10 * time.Minute
+
+// corsExposedHeaders are the response headers scripts may read besides the
+// CORS-safelisted ones.
+var corsExposedHeaders = []string{
+	"ETag", "Location", "Retry-After", "X-Request-ID",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+}
+
+// corsOrigin returns the value for Access-Control-Allow-Origin if origin may
+// call the API, or "".
+func (app *application) corsOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range app.config.CORSOrigins {
+		if allowed == "*" {
+			// A wildcard can't be combined with credentials (see
+			// Config.validate), so it is sent as is.
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// cors adds the CORS response headers to API requests from allowed origins.
+// It goes first in the API chain so that errors from the rest of the chain
+// can be read by the calling script too.
+func (app *application) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(app.config.CORSOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		if allow := app.corsOrigin(r.Header.Get("Origin")); allow != "" {
+			h.Set("Access-Control-Allow-Origin", allow)
+			h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			if app.config.CORSCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers OPTIONS requests for an API path served with methods.
+// A CORS preflight from an allowed origin asking for an allowed method and
+// headers gets the matching Access-Control-* headers; anything else just
+// gets the Allow header, which makes the browser refuse the request.
+func (app *application) preflight(methods []string) http.Handler {
+	allow := append([]string{http.MethodOptions}, methods...)
+	for _, m := range methods {
+		if m == http.MethodGet {
+			allow = append(allow, http.MethodHead)
+		}
+	}
+	sort.Strings(allow)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Allow", strings.Join(allow, ", "))
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		origin := app.corsOrigin(r.Header.Get("Origin"))
+		if len(app.config.CORSOrigins) > 0 {
+			h.Add("Vary", "Origin")
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if method != "" && origin != "" &&
+			contains(allow, method) && contains(app.config.CORSMethods, method) &&
+			app.corsHeadersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+
+			var methods []string
+			for _, m := range allow {
+				if contains(app.config.CORSMethods, m) {
+					methods = append(methods, m)
+				}
+			}
+
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(app.config.CORSHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(app.config.CORSHeaders, ", "))
+			}
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			if app.config.CORSCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// corsHeadersAllowed reports whether every header named in a preflight's
+// Access-Control-Request-Headers is on the allow-list.
+func (app *application) corsHeadersAllowed(requested string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ok := false
+		for _, allowed := range app.config.CORSHeaders {
+			ok = ok || strings.EqualFold(allowed, name)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether list holds s, ignoring case.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// corsHeaders returns the Access-Control-* headers of a response.
+func corsHeaders(header http.Header) http.Header {
+	h := http.Header{}
+	for k, v := range header {
+		if strings.HasPrefix(k, "Access-Control-") {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+func newCORSApplication(t *testing.T, origins []string, credentials bool) *application {
+	t.Helper()
+
+	app := newTestApplication(t)
+	app.config.CORSOrigins = origins
+	app.config.CORSMethods = stringList{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	app.config.CORSHeaders = stringList{"Authorization", "Content-Type", "If-Match"}
+	app.config.CORSCredentials = credentials
+	return app
+}
+
+func TestCORS(t *testing.T) {
+	const origin = "https://tools.example.com"
+
+	tests := []struct {
+		name            string
+		origins         []string
+		credentials     bool
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+		wantVary        bool
+	}{
+		{"Allowed", []string{origin}, false, origin, origin, false, true},
+		{"Allowed with credentials", []string{origin}, true, origin, origin, true, true},
+		{"Case of the origin", []string{origin}, false, "https://Tools.Example.com", "https://Tools.Example.com", false, true},
+		{"Other origin", []string{origin}, true, "https://evil.example.com", "", false, true},
+		{"No origin", []string{origin}, true, "", "", false, true},
+		{"Wildcard", []string{"*"}, false, "https://evil.example.com", "*", false, true},
+		{"CORS off", nil, false, origin, "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newCORSApplication(t, tt.origins, tt.credentials)
+			ts := newTestServer(t, app.routes())
+
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			code, header, _ := ts.do(t, http.MethodGet, "/api/v1/snippets", header, "")
+			if code != http.StatusOK {
+				t.Fatalf("got status %d; want %d", code, http.StatusOK)
+			}
+
+			cors := corsHeaders(header)
+			if tt.wantOrigin == "" {
+				if len(cors) > 0 {
+					t.Errorf("got CORS headers %v; want none", cors)
+				}
+			} else {
+				if got := cors.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+					t.Errorf("got Access-Control-Allow-Origin %q; want %q", got, tt.wantOrigin)
+				}
+				if got := cors.Get("Access-Control-Expose-Headers"); !strings.Contains(got, "ETag") {
+					t.Errorf("got Access-Control-Expose-Headers %q; want ETag among them", got)
+				}
+			}
+			if got := cors.Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("got credentials allowed %t; want %t", got, tt.wantCredentials)
+			}
+			if got := contains(header.Values("Vary"), "Origin"); got != tt.wantVary {
+				t.Errorf("got Vary %q; want Origin %t", header.Values("Vary"), tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	const origin = "https://tools.example.com"
+
+	tests := []struct {
+		name            string
+		credentials     bool
+		origin          string
+		method          string
+		headers         string
+		wantAllowed     bool
+		wantCredentials bool
+	}{
+		{"Allowed", false, origin, http.MethodPatch, "Authorization, if-match", true, false},
+		{"Allowed with credentials", true, origin, http.MethodDelete, "", true, true},
+		{"Other origin", true, "https://evil.example.com", http.MethodPatch, "", false, false},
+		{"Not a preflight", false, origin, "", "", false, false},
+		{"Method not served", false, origin, http.MethodPost, "", false, false},
+		{"Method not in the CORS list", false, origin, http.MethodOptions, "", false, false},
+		{"Header not allowed", false, origin, http.MethodPatch, "Authorization, X-Custom", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newCORSApplication(t, []string{origin}, tt.credentials)
+			ts := newTestServer(t, app.routes())
+
+			header := http.Header{"Origin": {tt.origin}}
+			if tt.method != "" {
+				header.Set("Access-Control-Request-Method", tt.method)
+			}
+			if tt.headers != "" {
+				header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			code, header, _ := ts.do(t, http.MethodOptions, "/api/v1/snippets/1", header, "")
+			if code != http.StatusNoContent {
+				t.Fatalf("got status %d; want %d", code, http.StatusNoContent)
+			}
+			if got, want := header.Get("Allow"), "DELETE, GET, HEAD, OPTIONS, PATCH, PUT"; got != want {
+				t.Errorf("got Allow %q; want %q", got, want)
+			}
+			for _, name := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !contains(header.Values("Vary"), name) {
+					t.Errorf("got Vary %q; want %s among them", header.Values("Vary"), name)
+				}
+			}
+
+			cors := corsHeaders(header)
+			if !tt.wantAllowed {
+				if len(cors) > 0 {
+					t.Errorf("got CORS headers %v; want none", cors)
+				}
+				return
+			}
+
+			want := map[string]string{
+				"Access-Control-Allow-Origin":  origin,
+				"Access-Control-Allow-Methods": "DELETE, GET, HEAD, PATCH, PUT",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type, If-Match",
+				"Access-Control-Max-Age":       "600",
+			}
+			if tt.wantCredentials {
+				want["Access-Control-Allow-Credentials"] = "true"
+			}
+			for name, value := range want {
+				if got := cors.Get(name); got != value {
+					t.Errorf("got %s %q; want %q", name, got, value)
+				}
+			}
+			if len(cors) != len(want) {
+				t.Errorf("got CORS headers %v; want only %v", cors, want)
+			}
+		})
+	}
+}
+
+func TestConfigValidateCORS(t *testing.T) {
+	cfg := &Config{CORSOrigins: stringList{"*"}, CORSCredentials: true}
+	if err := cfg.validate(); err == nil {
+		t.Error("credentials with a wildcard origin were accepted")
+	}
+
+	cfg.CORSOrigins = stringList{"https://tools.example.com"}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("credentials with a listed origin: %v", err)
+	}
+}
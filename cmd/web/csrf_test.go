@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestCSRF(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Behind LoadAndSave, as in the routes; /token hands out a token the
+	// way a page with a form does.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			token, err := app.csrfToken(r)
+			if err != nil {
+				t.Error(err)
+			}
+			w.Write([]byte(token))
+			return
+		}
+		w.Write([]byte("OK"))
+	})
+	ts := newTestServer(t, app.session.LoadAndSave(app.csrf(next)))
+
+	_, _, token := ts.get(t, "/token")
+
+	form := func(token string) string {
+		return url.Values{csrfField: {token}}.Encode()
+	}
+	formHeader := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+
+	tests := []struct {
+		name     string
+		method   string
+		header   http.Header
+		body     string
+		wantCode int
+	}{
+		{"GET", http.MethodGet, nil, "", http.StatusOK},
+		{"HEAD", http.MethodHead, nil, "", http.StatusOK},
+		{"OPTIONS", http.MethodOptions, nil, "", http.StatusOK},
+		{"Missing token", http.MethodPost, formHeader, "", http.StatusBadRequest},
+		{"Empty token", http.MethodPost, formHeader, form(""), http.StatusBadRequest},
+		{"Wrong token", http.MethodPost, formHeader, form(token + "x"), http.StatusBadRequest},
+		{"Valid token", http.MethodPost, formHeader, form(token), http.StatusOK},
+		{"Valid token in the header", http.MethodPost, http.Header{csrfHeader: {token}}, "", http.StatusOK},
+		{"Wrong token in the header", http.MethodPost, http.Header{csrfHeader: {"x" + token}}, form(token), http.StatusBadRequest},
+		{"PUT without a token", http.MethodPut, nil, "", http.StatusBadRequest},
+		{"DELETE with a token", http.MethodDelete, http.Header{csrfHeader: {token}}, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.do(t, tt.method, "/", tt.header, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	t.Run("Token from another session", func(t *testing.T) {
+		other := newTestServer(t, app.session.LoadAndSave(app.csrf(next)))
+		other.get(t, "/token")
+
+		code, _, _ := other.do(t, http.MethodPost, "/", formHeader, form(token))
+		if code != http.StatusBadRequest {
+			t.Errorf("got status %d; want %d", code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("No session", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form(token)))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		app.session.LoadAndSave(app.csrf(next)).ServeHTTP(rr, r)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("got status %d; want %d", rr.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// TestCSRFRoutes checks the middleware as the routes use it: forms need the
+// token, and API requests authenticated by a bearer token don't.
+func TestCSRFRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// Anonymous pages without forms leave the session alone.
+	_, header, _ := ts.get(t, "/")
+	if c := header.Get("Set-Cookie"); c != "" {
+		t.Errorf("the home page set a cookie: %q", c)
+	}
+
+	_, header, body := ts.get(t, "/user/login")
+	if header.Get("Set-Cookie") == "" {
+		t.Error("the login page didn't start a session")
+	}
+	token := extractCSRFToken(t, body)
+
+	login := url.Values{"email": {"nobody@example.com"}, "password": {"wrong"}}
+
+	code, _, _ := ts.postForm(t, "/user/login", login)
+	if code != http.StatusBadRequest {
+		t.Errorf("login without a token: got status %d; want %d", code, http.StatusBadRequest)
+	}
+
+	login.Set(csrfField, token)
+	code, _, body = ts.postForm(t, "/user/login", login)
+	if code != http.StatusOK || !strings.Contains(body, "Email or Password is incorrect") {
+		t.Errorf("login with a token: got status %d; want the form again", code)
+	}
+
+	if err := app.users.Insert("Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	bearer, err := app.tokens.Insert(1, "test", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header = http.Header{
+		"Authorization": {"Bearer " + bearer},
+		"Content-Type":  {"application/json"},
+	}
+	code, _, body = ts.do(t, http.MethodPost, "/api/v1/snippets", header, `{"title": "Title", "content": "Content", "expires_days": 7}`)
+	if code != http.StatusCreated {
+		t.Errorf("API request: got status %d; want %d: %s", code, http.StatusCreated, body)
+	}
+}
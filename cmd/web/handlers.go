@@ -2,14 +2,15 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gbih/snippetbox/pkg/forms"
 	"github.com/gbih/snippetbox/pkg/models"
@@ -41,22 +42,18 @@ func (nfs assetsFileSystem) Open(path string) (http.File, error) {
 
 // API Usage: curl -i localhost:4000/api/v1/test
 
+type apiTestPerson struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
 func (app *application) apiTest(w http.ResponseWriter, r *http.Request) {
-	data := []struct {
-		Name     string `json:"name"`
-		Location string `json:"location"`
-	}{
+	data := []apiTestPerson{
 		{"George", "日本"},
 		{"Izumi", "越後湯沢"},
 	}
 
-	js, err := json.MarshalIndent(data, "", "\t")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(js)
+	app.writeJSON(w, http.StatusOK, data)
 }
 
 //----------
@@ -140,18 +137,38 @@ func getHandler(w http.ResponseWriter, r *http.Request) {
 
 //----------
 
+// home lists the latest snippets as HTML, JSON or plain text, depending on
+// the Accept header. /api/v1/home is an alias that always answers JSON.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	format := app.negotiate(w, r)
+	if format == "" {
+		return
+	}
 
 	s, err := app.snippets.Latest()
 	if err != nil {
-		app.serverError(w, err)
+		app.serverErrorAs(w, r, format, err)
 		return
 	}
 
-	// New code
-	app.render(w, r, "home.page.html", &templateData{
-		Snippets: s,
-	})
+	switch format {
+	case formatJSON:
+		snippets := make([]*snippetJSON, 0, len(s))
+		for _, snippet := range s {
+			snippets = append(snippets, newListedSnippetJSON(snippet))
+		}
+		app.writeJSON(w, http.StatusOK, snippets)
+	case formatText:
+		texts := make([]string, 0, len(s))
+		for _, snippet := range s {
+			texts = append(texts, listedSnippetText(snippet))
+		}
+		writeText(w, http.StatusOK, strings.Join(texts, "\n"))
+	default:
+		app.render(w, r, "home.page.html", &templateData{
+			Snippets: s,
+		})
+	}
 
 	/*
 		Note:
@@ -214,62 +231,571 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	*/
 }
 
-// API Usage : curl -i localhost:4000/api/v1/home
+//----------
 
-func (app *application) apiHome(w http.ResponseWriter, r *http.Request) {
+// findSnippet loads the snippet a URL refers to, by ID or by slug (see
+// models.Snippet.Ref). Only public snippets can be fetched by ID, so the
+// others can't be found by counting, and private ones only by the users
+// allowed to change them. Everything else, including a malformed ID, is
+// reported as models.ErrNoRecord.
+func (app *application) findSnippet(r *http.Request, ref string) (*models.Snippet, error) {
+	var s *models.Snippet
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		if id < 1 {
+			return nil, models.ErrNoRecord
+		}
+		if s, err = app.snippets.Get(id); err != nil {
+			return nil, err
+		}
+		if s.Visibility != models.VisibilityPublic {
+			return nil, models.ErrNoRecord
+		}
+	} else {
+		if ref == "" {
+			return nil, models.ErrNoRecord
+		}
+		if s, err = app.snippets.GetBySlug(ref); err != nil {
+			return nil, err
+		}
+	}
 
-	s, err := app.snippets.Latest()
+	if s.Visibility == models.VisibilityPrivate && !app.canModify(r, s) {
+		return nil, models.ErrNoRecord
+	}
+
+	return s, nil
+}
+
+// snippetFromURL loads the snippet named by the :id URL parameter or, for
+// the legacy /api/v1/snippet alias, the id query parameter.
+func (app *application) snippetFromURL(r *http.Request) (*models.Snippet, error) {
+	q := r.URL.Query()
+	param := q.Get(":id")
+	if param == "" {
+		param = q.Get("id")
+	}
+
+	return app.findSnippet(r, param)
+}
+
+// showSnippet shows a snippet as HTML, JSON or plain text, depending on the
+// Accept header. /api/v1/snippet?id= is an alias that always answers JSON.
+func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
+	format := app.negotiate(w, r)
+	if format == "" {
+		return
+	}
+
+	s, err := app.snippetFromURL(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFoundAs(w, r, format)
+		} else {
+			app.serverErrorAs(w, r, format, err)
+		}
+		return
+	}
+
+	if err := app.snippetAccess(r, s); err != nil {
+		app.lockedAs(w, r, format, s, err)
+		return
+	}
+
+	switch format {
+	case formatJSON:
+		if notModified(w, r, s, snippetETag(s, formatJSON), false) {
+			return
+		}
+		app.writeJSON(w, http.StatusOK, newSnippetJSON(s))
+	case formatText:
+		if notModified(w, r, s, snippetETag(s, formatText), false) {
+			return
+		}
+		writeText(w, http.StatusOK, snippetText(s))
+	default:
+		// The page also shows who is logged in, what they may do and, in
+		// the forms logged-in users get, the session's CSRF token. A
+		// pending flash message has to be rendered, not revalidated.
+		canModify := app.canModify(r, s)
+		if !app.session.Exists(r.Context(), "flash") {
+			userID, csrfToken := 0, ""
+			if user := app.authenticatedUser(r); user != nil {
+				token, err := app.csrfToken(r)
+				if err != nil {
+					app.serverError(w, err)
+					return
+				}
+				userID, csrfToken = user.ID, token
+			}
+			variant := fmt.Sprintf("%s;user=%d;modify=%t;csrf=%s", formatHTML, userID, canModify, csrfToken)
+			if notModified(w, r, s, snippetETag(s, variant), true) {
+				return
+			}
+		}
+
+		// Manual template parsing and execution code refactored out
+		app.render(w, r, "show.page.html", &templateData{
+			Snippet:   s,
+			CanModify: canModify,
+		})
+	}
+}
+
+//----------
+
+func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "create.page.html", &templateData{
+		// Pass a new empty forms.Form object to the template.
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
+
+	// ParseForm populates r.Form and r.PostForm.
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Create a new forms.Form struct containing the POSTed data from the
+	// form, then use the validation methods to check the content.
+	form := forms.New(r.PostForm)
+	tags := validateSnippetForm(form)
+	validateSnippetPassword(form)
+
+	if !form.Valid() {
+		app.render(w, r, "create.page.html", &templateData{Form: form})
+		return
+	}
+
+	// Because the form data (with type url.Values) has been anonymously embedded
+	// in the form.Form struct, we can use the Get() method to retrieve
+	// the validated value for a particular form field.
+	id, err := app.snippets.Insert(
+		app.authenticatedUser(r).ID,
+		form.Get("title"),
+		form.Get("content"),
+		form.Get("expires"),
+		form.Get("visibility"),
+		form.Get("password"),
+		tags,
+	)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	js, err := json.MarshalIndent(s, "", "\t")
+	// Unlisted and private snippets live at their slug.
+	s, err := app.snippets.Get(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		app.serverError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application-json; charset=UTF-8")
-	w.Write(js)
+	// Use the Put() method to add a string value ("Your snippet was saved
+	// successfully!") and the corresponding key ("flash") to the session
+	// data. Note that if there's no existing session for the current user
+	// (or their session has expired) then a new, empty, session for them
+	// will automatically be created by the session middleware.
+	//
+	// Cookie style:
+	// app.session.Put(r, "flash", "Snippet successfully created!")
+	//
+	// Server-side style:Store a new key and value in the session data.
+	session.Put(r.Context(), "flash", "Snippet successfully created!")
+
+	http.Redirect(w, r, "/snippet/"+s.Ref(), http.StatusSeeOther)
 }
 
 //----------
 
-func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "signup.page.html", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	s, err := app.snippets.Get(id)
+	// Validate the form contents using the form helper we made earlier.
+	form := forms.New(r.PostForm)
+	form.Required("name", "email", "password")
+	form.MaxLength("name", 255)
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	form.MinLength("password", 10)
+
+	// If there are any errors, redisplay the signup form.
+	if !form.Valid() {
+		app.render(w, r, "signup.page.html", &templateData{Form: form})
+		return
+	}
+
+	// Try to create a new user record in the database. If the email already
+	// exists add an error message to the form and re-display it.
+	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.Errors.Add("email", "Address is already in use")
+			app.render(w, r, "signup.page.html", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "login.page.html", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Check whether the credentials are valid. If they're not, add a generic
+	// error message to the form failures map and re-display the login page.
+	form := forms.New(r.PostForm)
+	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("generic", "Email or Password is incorrect")
+			app.render(w, r, "login.page.html", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Renew the session token whenever the privilege level changes, to
+	// prevent session fixation attacks.
+	err = app.session.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Remove(r.Context(), csrfSessionKey)
+
+	// Add the ID of the current user to the session, so that they are now
+	// 'logged in'.
+	app.session.Put(r.Context(), "authenticatedUserID", id)
+
+	// Send the user back to the page they were trying to reach, if any.
+	path := app.session.PopString(r.Context(), "redirectPathAfterLogin")
+	if path == "" {
+		path = "/snippet/create"
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
+	err := app.session.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Remove(r.Context(), csrfSessionKey)
+	app.session.Remove(r.Context(), unlockedSessionKey)
+
+	// Remove the authenticatedUserID from the session data so that the user
+	// is 'logged out'.
+	app.session.Remove(r.Context(), "authenticatedUserID")
+
+	app.session.Put(r.Context(), "flash", "You've been logged out successfully!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// validateSnippetForm runs the checks of the create form and of full API
+// updates, and returns the normalized tags from the free-form "tags" field.
+func validateSnippetForm(form *forms.Form) []string {
+	form.Required("title", "content", "expires")
+	return validateSnippetFields(form)
+}
+
+// validateSnippetFields checks the format of whichever snippet fields are
+// present, without requiring any of them. The JSON API uses it directly for
+// partial (PATCH) updates.
+func validateSnippetFields(form *forms.Form) []string {
+	form.MaxLength("title", 100)
+	form.PermittedValues("expires", "365", "7", "1")
+	form.PermittedValues("visibility", models.Visibilities...)
+
+	// Tags are entered as a single comma or space separated field, e.g.
+	// "go, sql testing". Normalize them here so the form is redisplayed
+	// with exactly what will be stored.
+	tags, err := models.ParseTags(form.Get("tags"))
+	if err != nil {
+		form.Errors.Add("tags", strings.TrimPrefix(err.Error(), models.ErrInvalidTag.Error()+": "))
+		return nil
+	}
+	form.Set("tags", strings.Join(tags, ", "))
+
+	return tags
+}
+
+//----------
+
+// snippetForUpdate loads the snippet named by the :id URL parameter and checks
+// that the current user is allowed to change it. If not, it writes the error
+// response itself and returns nil.
+func (app *application) snippetForUpdate(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s, err := app.findSnippet(r, r.URL.Query().Get(":id"))
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
 			app.serverError(w, err)
 		}
+		return nil
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return s
+}
+
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetForUpdate(w, r)
+	if s == nil {
 		return
 	}
 
-	// Manual template parsing and execution code refactored out
-	app.render(w, r, "show.page.html", &templateData{
+	// Pre-fill the create form with the stored snippet. With no "expires"
+	// value the form offers to keep the current expiry.
+	form := forms.New(url.Values{})
+	form.Set("title", s.Title)
+	form.Set("content", s.Content)
+	form.Set("tags", strings.Join(s.Tags, ", "))
+	form.Set("visibility", s.Visibility)
+
+	app.render(w, r, "create.page.html", &templateData{
+		Form:    form,
 		Snippet: s,
 	})
+}
+
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetForUpdate(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Unlike on create, the expiry may be left empty, which keeps the
+	// current one.
+	form := forms.New(r.PostForm)
+	form.Required("title", "content")
+	tags := validateSnippetFields(form)
+
+	if !form.Valid() {
+		app.render(w, r, "create.page.html", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	err = app.snippets.Update(
+		s.ID,
+		app.authenticatedUser(r).ID,
+		form.Get("title"),
+		form.Get("content"),
+		form.Get("expires"),
+		form.Get("visibility"),
+		tags,
+	)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// A change of visibility can change the URL.
+	s, err = app.snippets.Get(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/snippet/"+s.Ref(), http.StatusSeeOther)
+}
+
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetForUpdate(w, r)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+//----------
+
+// snippetAndRevision loads the snippet named by the :id URL parameter and,
+// when the route has one, the revision named by :rev. It writes the error
+// response itself and returns nil if either is missing, or if the snippet
+// is locked.
+func (app *application) snippetAndRevision(w http.ResponseWriter, r *http.Request) (*models.Snippet, *models.Revision) {
+	s, err := app.findSnippet(r, r.URL.Query().Get(":id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil
+	}
+
+	if err := app.snippetAccess(r, s); err != nil {
+		app.lockedAs(w, r, formatHTML, s, err)
+		return nil, nil
+	}
+
+	if r.URL.Query().Get(":rev") == "" {
+		return s, nil
+	}
+
+	number, err := strconv.Atoi(r.URL.Query().Get(":rev"))
+	if err != nil || number < 1 {
+		app.notFound(w)
+		return nil, nil
+	}
+
+	rev, err := app.snippets.Revision(s.ID, number)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil
+	}
+
+	return s, rev
+}
+
+// authorNames maps the author IDs of revs to user names. Authors whose
+// accounts are gone are left out.
+func (app *application) authorNames(revs ...*models.Revision) (map[int]string, error) {
+	names := map[int]string{}
+
+	for _, rev := range revs {
+		if _, ok := names[rev.UserID]; ok || rev.UserID == 0 {
+			continue
+		}
+
+		u, err := app.users.Get(rev.UserID)
+		if errors.Is(err, models.ErrNoRecord) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		names[rev.UserID] = u.Name
+	}
+
+	return names, nil
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, _ := app.snippetAndRevision(w, r)
+	if s == nil {
+		return
+	}
+
+	revs, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	authors, err := app.authorNames(revs...)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.html", &templateData{
+		Snippet:   s,
+		Revisions: revs,
+		Authors:   authors,
+		CanModify: app.canModify(r, s),
+	})
+}
+
+func (app *application) showRevision(w http.ResponseWriter, r *http.Request) {
+	s, rev := app.snippetAndRevision(w, r)
+	if rev == nil {
+		return
+	}
+
+	authors, err := app.authorNames(rev)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
+	app.render(w, r, "revision.page.html", &templateData{
+		Snippet:   s,
+		Revision:  rev,
+		Authors:   authors,
+		CanModify: app.canModify(r, s),
+	})
 }
 
-// API Usage: curl -i 'http://localhost:4000/api/v1/snippet?id=1'
+func (app *application) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetForUpdate(w, r)
+	if s == nil {
+		return
+	}
 
-func (app *application) apiShowSnippet(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil || id < 1 {
+	number, err := strconv.Atoi(r.URL.Query().Get(":rev"))
+	if err != nil || number < 1 {
 		app.notFound(w)
 		return
 	}
 
-	s, err := app.snippets.Get(id)
+	err = app.snippets.Restore(s.ID, number, app.authenticatedUser(r).ID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -278,115 +804,208 @@ func (app *application) apiShowSnippet(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	js, err := json.MarshalIndent(s, "", "\t")
+
+	app.session.Put(r.Context(), "flash", fmt.Sprintf("Revision %d successfully restored!", number))
+
+	http.Redirect(w, r, "/snippet/"+s.Ref(), http.StatusSeeOther)
+}
+
+//----------
+
+// listSnippets serves both /snippets and /tag/:name; the latter is the same
+// listing with the tag filter taken from the path.
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path := "/snippets"
+	if name := q.Get(":name"); name != "" {
+		q.Set("tag", name)
+		path = "/tag/" + url.PathEscape(name)
+	}
+
+	opts, form := listOptions(q)
+	if !form.Valid() {
+		app.render(w, r, "snippets.page.html", &templateData{Form: form, Tag: opts.Tag, ListURL: path})
+		return
+	}
+
+	page, err := app.snippets.List(opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
-	//fmt.Fprintf(w, "%v", s)
-	w.Header().Set("Content-Type", "application-json; charset=utf-8")
-	w.Write(js)
+
+	// The tag is already part of the /tag/:name path.
+	if path != "/snippets" {
+		q.Del("tag")
+	}
+
+	app.render(w, r, "snippets.page.html", &templateData{
+		Form:    form,
+		Page:    page,
+		Tag:     opts.Tag,
+		ListURL: path,
+		NextURL: pageURL(path, q, page.Next),
+		PrevURL: pageURL(path, q, page.Prev),
+	})
 }
 
 //----------
 
-func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.html", &templateData{
-		// Pass a new empty forms.Form object to the template.
-		Form: forms.New(nil),
-	})
+// searchPage reads the q and page query parameters and runs the search. An
+// empty query returns an empty page without touching the database.
+func (app *application) searchPage(r *http.Request) (*models.SearchPage, error) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	if q == "" {
+		return &models.SearchPage{Page: page, Results: []*models.SearchResult{}}, nil
+	}
+
+	return app.snippets.Search(q, page)
 }
 
-func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
+func (app *application) searchSnippets(w http.ResponseWriter, r *http.Request) {
+	sp, err := app.searchPage(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	// ParseForm populates r.Form and r.PostForm.
-	err := r.ParseForm()
+	td := &templateData{Search: sp}
+	if sp.Page > 1 {
+		td.PrevURL = fmt.Sprintf("/search?q=%s&page=%d", url.QueryEscape(sp.Query), sp.Page-1)
+	}
+	if sp.More {
+		td.NextURL = fmt.Sprintf("/search?q=%s&page=%d", url.QueryEscape(sp.Query), sp.Page+1)
+	}
+
+	app.render(w, r, "search.page.html", td)
+}
+
+// API Usage: curl -i 'http://localhost:4000/api/v1/search?q=snail&page=1'
+
+func (app *application) apiSearchSnippets(w http.ResponseWriter, r *http.Request) {
+	sp, err := app.searchPage(r)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.apiServerError(w, r, err)
+		return
+	}
+	if sp.Query == "" {
+		app.apiProblem(w, r, http.StatusBadRequest, codeBadRequest, "The q parameter is required.")
 		return
 	}
 
-	// Create a new forms.Form struct containing the POSTed data from the
-	// form, then use the validation methods to check the content.
-	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
-	form.PermittedValues("items", "foo", "bar", "baz")
+	results := make([]*searchResultJSON, 0, len(sp.Results))
+	for _, res := range sp.Results {
+		results = append(results, &searchResultJSON{newListedSnippetJSON(res.Snippet), res.Rank, highlight(res.Headline)})
+	}
 
-	// Multiple-Value Fields
-	// Strictly speaking, the r.PostForm.Get() method that we’ve used above only returns the first value for a specific form field. This means you can’t use it with form fields which potentially send multiple values, such as a group of checkboxes.
-	// In this case you’ll need to work with the r.PostForm map directly. The underlying type of the r.PostForm map is url.Values, which in turn has the underlying type map[string][]string. So, for fields with multiple values you can loop over the underlying map to access them like so:
+	app.writeJSON(w, http.StatusOK, &searchPageJSON{sp.Query, sp.Page, sp.More, results})
+}
 
-	// DEBUG
-	// for i, item := range r.PostForm["items"] {
-	// 	fmt.Fprintf(w, "%d: Item %s\n", i, item)
-	// }
-	const foo = "foo"
-	const bar = "bar"
-	const baz = "baz"
-	const blank = "---"
+//----------
 
-	// the r.PostForm.Get() method only returns the first value for a specific form field. This means you can’t use it with form fields which potentially send multiple values, such as a group of checkboxes.
-	// Here we need to work with the r.PostForm map directly. The underlying type of the r.PostForm map is url.Values, which in turn has the underlying type map[string][]string.
+func (app *application) listTags(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippets.TagCounts()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "tags.page.html", &templateData{TagCounts: counts})
+}
 
-	// Checkboxes (many-in-a-set) validation
-	// https://www.alexedwards.net/blog/validation-snippets-for-go#checkboxes-many-in-set
-	// GB Map is the correct data structure here, as it enables using a
-	// key-value pair that we can then iterate through and check
-	//set := map[string]bool{"foo": true, "baz": true, "bar": true}
-	// var foo string
-	// var baz string
-	// var bar string
+// API Usage: curl -i localhost:4000/api/v1/tags
 
-	for _, item := range r.PostForm["items"] {
-		// have to manually add to form data structure so we can
-		// more easily check and manipulate it
-		form.Add(item, item)
+func (app *application) apiListTags(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippets.TagCounts()
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
 	}
 
-	if form.Get(foo) != foo {
-		form.Set(foo, blank)
+	tags := make([]*tagJSON, 0, len(counts))
+	for _, tc := range counts {
+		tags = append(tags, &tagJSON{tc.Name, tc.Count})
 	}
-	if form.Get(bar) != bar {
-		form.Set(bar, blank)
+
+	app.writeJSON(w, http.StatusOK, tags)
+}
+
+//----------
+
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, form *forms.Form, newToken string) {
+	tokens, err := app.tokens.List(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
-	if form.Get(baz) != baz {
-		form.Set(baz, blank)
+
+	app.render(w, r, "tokens.page.html", &templateData{
+		Form:     form,
+		Tokens:   tokens,
+		NewToken: newToken,
+	})
+}
+
+func (app *application) listTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, forms.New(url.Values{}), "")
+}
+
+func (app *application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
 	}
 
+	form := forms.New(r.PostForm)
+	form.Required("name", "scope")
+	form.MaxLength("name", 100)
+	form.PermittedValues("scope", models.ScopeRead, models.ScopeWrite)
+
 	if !form.Valid() {
-		app.render(w, r, "create.page.html", &templateData{Form: form})
+		app.renderTokens(w, r, form, "")
 		return
 	}
 
-	// Because the form data (with type url.Values) has been anonymously embedded
-	// in the form.Form struct, we can use the Get() method to retrieve
-	// the validated value for a particular form field.
-	id, err := app.snippets.Insert(
-		form.Get("title"),
-		form.Get("content"),
-		form.Get("expires"),
-		form.Get("foo"),
-		form.Get("bar"),
-		form.Get("baz"),
-	)
+	token, err := app.tokens.Insert(app.authenticatedUser(r).ID, form.Get("name"), form.Get("scope"))
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	// Use the Put() method to add a string value ("Your snippet was saved
-	// successfully!") and the corresponding key ("flash") to the session
-	// data. Note that if there's no existing session for the current user
-	// (or their session has expired) then a new, empty, session for them
-	// will automatically be created by the session middleware.
-	//
-	// Cookie style:
-	// app.session.Put(r, "flash", "Snippet successfully created!")
-	//
-	// Server-side style:Store a new key and value in the session data.
-	session.Put(r.Context(), "flash", "Snippet successfully created!")
+	// The plain-text token is only ever shown on this response, so render
+	// the page directly instead of redirecting.
+	app.renderTokens(w, r, forms.New(url.Values{}), token)
+}
+
+func (app *application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Revoke(app.authenticatedUser(r).ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Token revoked.")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
 }
@@ -0,0 +1,790 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gbih/snippetbox/pkg/models"
+	"github.com/gbih/snippetbox/pkg/ratelimit"
+)
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, title := range []string{"First", "Second", "Third", "Fourth"} {
+		if _, err := app.snippets.Insert(0, title, "Content", "7", models.VisibilityPublic, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := app.snippets.Insert(0, "Hidden", "Content", "7", models.VisibilityUnlisted, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", code, http.StatusOK)
+	}
+
+	// The three newest public snippets.
+	for _, title := range []string{"Second", "Third", "Fourth"} {
+		if !strings.Contains(body, title) {
+			t.Errorf("body doesn't contain %q", title)
+		}
+	}
+	for _, title := range []string{"First", "Hidden"} {
+		if strings.Contains(body, title) {
+			t.Errorf("body contains %q", title)
+		}
+	}
+}
+
+func TestShowSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.snippets.Insert(0, "An old silent pond", "A frog jumps into the pond", "7", models.VisibilityPublic, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlisted, err := app.snippets.Insert(0, "Unlisted", "Content", "7", models.VisibilityUnlisted, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippets.Get(unlisted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid ID", "/snippet/1", http.StatusOK, "A frog jumps into the pond"},
+		{"Non-existent ID", "/snippet/99", http.StatusNotFound, ""},
+		{"Negative ID", "/snippet/-1", http.StatusNotFound, ""},
+		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, ""},
+		{"String ID", "/snippet/foo", http.StatusNotFound, ""},
+		{"Unlisted by ID", "/snippet/2", http.StatusNotFound, ""},
+		{"Unlisted by slug", "/snippet/" + s.Slug, http.StatusOK, "Unlisted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Unlisted hides its ID", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/"+s.Slug)
+		if strings.Contains(body, "#2") || !strings.Contains(body, "<span>"+s.Slug+"</span>") {
+			t.Error("the page shows the ID instead of the slug")
+		}
+
+		_, _, body = ts.do(t, http.MethodGet, "/snippet/"+s.Slug, http.Header{"Accept": {formatText}}, "")
+		if want := s.Slug + " Unlisted\n"; !strings.HasPrefix(body, want) {
+			t.Errorf("got text starting %q; want %q", strings.SplitN(body, "\n", 2)[0], want)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		code, header, body := ts.do(t, http.MethodGet, "/snippet/1", http.Header{"Accept": {"application/json"}}, "")
+		if code != http.StatusOK {
+			t.Fatalf("got status %d; want %d", code, http.StatusOK)
+		}
+		if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("got Content-Type %q; want application/json", ct)
+		}
+
+		var got snippetJSON
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != id || got.Title != "An old silent pond" {
+			t.Errorf("got snippet %d %q; want %d %q", got.ID, got.Title, id, "An old silent pond")
+		}
+	})
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+		if code != http.StatusSeeOther {
+			t.Errorf("got status %d; want %d", code, http.StatusSeeOther)
+		}
+		if loc := header.Get("Location"); loc != "/user/login" {
+			t.Errorf("got Location %q; want /user/login", loc)
+		}
+	})
+
+	ts.login(t, app)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	if !strings.Contains(body, "<input type='radio' name='expires' value='365' checked>") {
+		t.Error("the create form doesn't default to one year")
+	}
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		expires  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid", "Title", "Content", "7", http.StatusSeeOther, ""},
+		{"Empty title", "", "Content", "7", http.StatusOK, "This field cannot be blank"},
+		{"Empty content", "Title", "", "7", http.StatusOK, "This field cannot be blank"},
+		{"Invalid expires", "Title", "Content", "2", http.StatusOK, "This field is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("visibility", models.VisibilityPublic)
+			form.Add(csrfField, token)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSignupUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/user/signup")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		userName string
+		email    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid", "Bob", "bob@example.com", "validPa$$word", http.StatusSeeOther, ""},
+		{"Empty name", "", "bob@example.com", "validPa$$word", http.StatusOK, "This field cannot be blank"},
+		{"Long name", strings.Repeat("b", 256), "bob@example.com", "validPa$$word", http.StatusOK, "This field is too long (maximum is 255 characters)"},
+		{"Empty email", "Bob", "", "validPa$$word", http.StatusOK, "This field cannot be blank"},
+		{"Invalid email", "Bob", "bob@example.", "validPa$$word", http.StatusOK, "This field is invalid"},
+		{"Empty password", "Bob", "bob@example.com", "", http.StatusOK, "This field cannot be blank"},
+		{"Short password", "Bob", "bob@example.com", "pa$$word", http.StatusOK, "This field is too short (minimum is 10 characters)"},
+		{"Duplicate email", "Bob", "bob@example.com", "validPa$$word", http.StatusOK, "Address is already in use"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add(csrfField, token)
+
+			code, header, body := ts.postForm(t, "/user/signup", form)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if code == http.StatusSeeOther && header.Get("Location") != "/user/login" {
+				t.Errorf("got Location %q; want /user/login", header.Get("Location"))
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q", tt.wantBody)
+			}
+			if code == http.StatusOK && strings.Contains(body, tt.password) && tt.password != "" {
+				t.Error("the form was redisplayed with the password filled in")
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.users.Insert("Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	// sessionCookie returns the value of the client's session cookie.
+	sessionCookie := func() string {
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range ts.Client().Jar.Cookies(u) {
+			if c.Name == app.session.Cookie.Name {
+				return c.Value
+			}
+		}
+		return ""
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+	before := sessionCookie()
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{"Unknown email", "bob@example.com", "pa55word", http.StatusOK, "Email or Password is incorrect"},
+		{"Wrong password", "alice@example.com", "pa55word!", http.StatusOK, "Email or Password is incorrect"},
+		{"Empty", "", "", http.StatusOK, "Email or Password is incorrect"},
+		{"Valid", "alice@example.com", "pa55word", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add(csrfField, extractCSRFToken(t, body))
+
+			code, header, respBody := ts.postForm(t, "/user/login", form)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(respBody, tt.wantBody) {
+				t.Errorf("body doesn't contain %q", tt.wantBody)
+			}
+			if code == http.StatusOK {
+				if sessionCookie() != before {
+					t.Error("a failed login renewed the session token")
+				}
+				return
+			}
+
+			if loc := header.Get("Location"); loc != "/snippet/create" {
+				t.Errorf("got Location %q; want /snippet/create", loc)
+			}
+			if sessionCookie() == before {
+				t.Error("logging in didn't renew the session token")
+			}
+		})
+	}
+
+	code, _, _ := ts.get(t, "/snippet/create")
+	if code != http.StatusOK {
+		t.Fatalf("after login: got status %d; want %d", code, http.StatusOK)
+	}
+
+	_, _, body = ts.get(t, "/")
+	form := url.Values{}
+	form.Add(csrfField, extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("logout: got status %d; want %d", code, http.StatusSeeOther)
+	}
+
+	code, _, _ = ts.get(t, "/snippet/create")
+	if code != http.StatusSeeOther {
+		t.Errorf("after logout: got status %d; want %d", code, http.StatusSeeOther)
+	}
+}
+
+func TestSearchSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	snippets := []struct{ title, content, visibility string }{
+		{"Pond", "An old silent pond. A <b>frog</b> jumps in.", models.VisibilityPublic},
+		{"Moon", "The autumn moon.", models.VisibilityPublic},
+		{"Hidden frog", "A frog nobody links to.", models.VisibilityUnlisted},
+	}
+	for _, s := range snippets {
+		if _, err := app.snippets.Insert(0, s.title, s.content, "7", s.visibility, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCode  int
+		wantBody  []string
+		wantNotIn []string
+	}{
+		{
+			name:      "HTML escaped, hits marked",
+			path:      "/search?q=FROG",
+			wantCode:  http.StatusOK,
+			wantBody:  []string{"A &lt;b&gt;<mark>frog</mark>&lt;/b&gt; jumps in.", "<a href='/snippet/1'>Pond</a>"},
+			wantNotIn: []string{"<b>frog</b>", "Hidden frog", "Moon"},
+		},
+		{
+			name:     "No match",
+			path:     "/search?q=toad",
+			wantCode: http.StatusOK,
+			wantBody: []string{`No snippets match "toad".`},
+		},
+		{
+			name:     "Query escaped",
+			path:     "/search?q=%3Ci%3Etoad",
+			wantCode: http.StatusOK,
+			wantBody: []string{`No snippets match "&lt;i&gt;toad".`},
+		},
+		{
+			name:     "API",
+			path:     "/api/v1/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: []string{`"headline": "An old silent pond. A \u0026lt;b\u0026gt;\u003cmark\u003efrog\u003c/mark\u003e\u0026lt;/b\u0026gt; jumps in."`},
+		},
+		{
+			name:     "API without a query",
+			path:     "/api/v1/search",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.path)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("body doesn't contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.wantNotIn {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, app)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	token := extractCSRFToken(t, body)
+
+	create := func(title, tags string) (int, string) {
+		form := url.Values{}
+		form.Add("title", title)
+		form.Add("content", "Content")
+		form.Add("expires", "7")
+		form.Add("visibility", models.VisibilityPublic)
+		form.Add("tags", tags)
+		form.Add(csrfField, token)
+
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+		return code, body
+	}
+
+	if code, _ := create("Go and SQL", "Go, SQL  go"); code != http.StatusSeeOther {
+		t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+	}
+	if code, _ := create("Just Go", "GO"); code != http.StatusSeeOther {
+		t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+	}
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(s.Tags, ","); got != "go,sql" {
+		t.Errorf("got tags %q; want go,sql", got)
+	}
+
+	t.Run("Invalid tag", func(t *testing.T) {
+		code, body := create("Bad tag", "go <b>")
+		if code != http.StatusOK {
+			t.Fatalf("got status %d; want %d", code, http.StatusOK)
+		}
+		if want := "&#34;&lt;b&gt;&#34; may only contain letters, digits and"; !strings.Contains(body, want) {
+			t.Errorf("body doesn't contain %q", want)
+		}
+	})
+
+	t.Run("Tag page", func(t *testing.T) {
+		_, _, body := ts.get(t, "/tag/go")
+		for _, title := range []string{"Go and SQL", "Just Go"} {
+			if !strings.Contains(body, title) {
+				t.Errorf("body doesn't contain %q", title)
+			}
+		}
+
+		_, _, body = ts.get(t, "/tag/sql")
+		if strings.Contains(body, "Just Go") {
+			t.Error("/tag/sql lists a snippet without the tag")
+		}
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		_, _, body := ts.get(t, "/api/v1/tags")
+		var got []tagJSON
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := []tagJSON{{"go", 2}, {"sql", 1}}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.snippets.Insert(1, "First", "One", "7", models.VisibilityPublic, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.snippets.Update(id, 1, "Second", "Two", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Anonymous restore", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{csrfField: {extractCSRFToken(t, body)}}
+		code, header, _ := ts.postForm(t, "/snippet/1/history/1/restore", form)
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+			t.Errorf("got status %d to %q; want %d to /user/login", code, header.Get("Location"), http.StatusSeeOther)
+		}
+	})
+
+	ts.login(t, app)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCode  int
+		wantBody  []string
+		wantNotIn []string
+	}{
+		{"History", "/snippet/1/history", http.StatusOK,
+			[]string{"<a href='/snippet/1/history/2'>#2</a>", "<a href='/snippet/1/history/1'>#1</a>", "<td>Alice</td>"}, nil},
+		{"Old revision", "/snippet/1/history/1", http.StatusOK,
+			[]string{"<strong>First</strong>", "<code>One</code>", "Restore this revision"}, []string{"Two"}},
+		{"Missing revision", "/snippet/1/history/3", http.StatusNotFound, nil, nil},
+		{"Bad revision", "/snippet/1/history/x", http.StatusNotFound, nil, nil},
+		{"Missing snippet", "/snippet/2/history", http.StatusNotFound, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.path)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("body doesn't contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.wantNotIn {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body contains %q", unwanted)
+				}
+			}
+		})
+	}
+
+	t.Run("Restore", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/1/history/1")
+		form := url.Values{csrfField: {extractCSRFToken(t, body)}}
+
+		code, header, _ := ts.postForm(t, "/snippet/1/history/1/restore", form)
+		if code != http.StatusSeeOther || header.Get("Location") != "/snippet/1" {
+			t.Fatalf("got status %d to %q; want %d to /snippet/1", code, header.Get("Location"), http.StatusSeeOther)
+		}
+
+		_, _, body = ts.get(t, "/snippet/1")
+		for _, want := range []string{"Revision 1 successfully restored!", "<strong>First</strong>", "One"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body doesn't contain %q", want)
+			}
+		}
+
+		_, _, body = ts.get(t, "/snippet/1/history")
+		if !strings.Contains(body, "<a href='/snippet/1/history/3'>#3</a>") {
+			t.Error("the restore wasn't recorded as revision 3")
+		}
+
+		code, _, _ = ts.postForm(t, "/snippet/1/history/9/restore", form)
+		if code != http.StatusNotFound {
+			t.Errorf("restoring a missing revision: got status %d; want %d", code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestEditSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, app)
+
+	id, err := app.snippets.Insert(1, "Title", "Content", "365", models.VisibilityPublic, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := s.Expires
+
+	_, _, body := ts.get(t, "/snippet/1/edit")
+	if !strings.Contains(body, "<input type='radio' name='expires' value='' checked>") {
+		t.Error("the edit form doesn't keep the current expiry by default")
+	}
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name    string
+		expires string
+		want    func(time.Time) bool
+	}{
+		{"Kept", "", func(t time.Time) bool { return t.Equal(expires) }},
+		{"Changed", "1", func(t time.Time) bool { return t.Before(expires.AddDate(0, 0, -300)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", "Content")
+			form.Add("expires", tt.expires)
+			form.Add("visibility", models.VisibilityPublic)
+			form.Add(csrfField, token)
+
+			code, _, _ := ts.postForm(t, "/snippet/1/edit", form)
+			if code != http.StatusSeeOther {
+				t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+			}
+
+			s, err := app.snippets.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(s.Expires) {
+				t.Errorf("got expiry %v, was %v", s.Expires, expires)
+			}
+		})
+	}
+}
+
+// insertProtected stores a public snippet locked with password, hashed at
+// the lowest bcrypt cost to keep the tests quick, and returns its ID.
+func insertProtected(t *testing.T, app *application, title, content, password string) int {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := app.snippets.InsertMany([]*models.NewSnippet{
+		{Title: title, Content: content, Expires: "7", PasswordHash: hash},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids[0]
+}
+
+func TestSnippetPassword(t *testing.T) {
+	app := newTestApplication(t)
+	app.unlockLimiter = ratelimit.New(ratelimit.Limit{Burst: 2, Period: time.Minute})
+	ts := newTestServer(t, app.routes())
+
+	const content = "The secret content"
+	id := strconv.Itoa(insertProtected(t, app, "Locked", content, "open sesame"))
+
+	jsonAccept := http.Header{"Accept": {formatJSON}}
+
+	t.Run("Locked", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			path     string
+			header   http.Header
+			wantCode int
+			wantBody string
+		}{
+			{"HTML", "/snippet/" + id, nil, http.StatusForbidden, "This snippet is password-protected."},
+			{"JSON", "/snippet/" + id, jsonAccept, http.StatusForbidden, codePasswordRequired},
+			{"Text", "/snippet/" + id, http.Header{"Accept": {formatText}}, http.StatusForbidden, snippetPasswordHeader},
+			{"API", "/api/v1/snippets/" + id, nil, http.StatusForbidden, codePasswordRequired},
+			{"Raw", "/snippet/" + id + "/raw", nil, http.StatusForbidden, snippetPasswordHeader},
+			{"Download", "/snippet/" + id + "/download", nil, http.StatusForbidden, snippetPasswordHeader},
+			{"Listing", "/snippets", jsonAccept, http.StatusOK, "Locked"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, _, body := ts.do(t, http.MethodGet, tt.path, tt.header, "")
+				if code != tt.wantCode {
+					t.Errorf("got status %d; want %d", code, tt.wantCode)
+				}
+				if !strings.Contains(body, tt.wantBody) {
+					t.Errorf("body doesn't contain %q", tt.wantBody)
+				}
+				if strings.Contains(body, content) {
+					t.Error("body gives the content away")
+				}
+			})
+		}
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			path     string
+			password string
+			wantCode int
+			wantBody string
+		}{
+			{"API", "/api/v1/snippets/" + id, "open sesame", http.StatusOK, content},
+			{"Raw", "/snippet/" + id + "/raw", "open sesame", http.StatusOK, content},
+			{"Wrong password", "/snippet/" + id + "/raw", "open barley", http.StatusForbidden, "wrong"},
+			{"Wrong password in JSON", "/api/v1/snippets/" + id, "open barley", http.StatusForbidden, codeInvalidPassword},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				header := http.Header{snippetPasswordHeader: {tt.password}}
+				code, _, body := ts.do(t, http.MethodGet, tt.path, header, "")
+				if code != tt.wantCode {
+					t.Errorf("got status %d; want %d", code, tt.wantCode)
+				}
+				if !strings.Contains(body, tt.wantBody) {
+					t.Errorf("body doesn't contain %q", tt.wantBody)
+				}
+			})
+		}
+	})
+
+	t.Run("Throttle", func(t *testing.T) {
+		id := strconv.Itoa(insertProtected(t, app, "Throttled", content, "open sesame"))
+		other := strconv.Itoa(insertProtected(t, app, "Other", content, "open sesame"))
+
+		// Right passwords are refunded, so they never use up the limit.
+		steps := []struct {
+			id       string
+			password string
+			wantCode int
+		}{
+			{id, "open sesame", http.StatusOK},
+			{id, "open sesame", http.StatusOK},
+			{id, "open sesame", http.StatusOK},
+			{id, "wrong", http.StatusForbidden},
+			{id, "wrong", http.StatusForbidden},
+			{id, "open sesame", http.StatusTooManyRequests},
+			{other, "open sesame", http.StatusOK},
+		}
+
+		for i, st := range steps {
+			header := http.Header{snippetPasswordHeader: {st.password}}
+			code, header, _ := ts.do(t, http.MethodGet, "/snippet/"+st.id+"/raw", header, "")
+			if code != st.wantCode {
+				t.Errorf("step %d: got status %d; want %d", i, code, st.wantCode)
+			}
+			if code == http.StatusTooManyRequests && header.Get("Retry-After") == "" {
+				t.Errorf("step %d: no Retry-After", i)
+			}
+		}
+	})
+
+	t.Run("Unlock form", func(t *testing.T) {
+		id := strconv.Itoa(insertProtected(t, app, "Unlocked", content, "open sesame"))
+
+		_, _, body := ts.get(t, "/snippet/"+id)
+		token := extractCSRFToken(t, body)
+
+		code, _, body := ts.postForm(t, "/snippet/"+id+"/unlock", url.Values{"password": {"open barley"}, csrfField: {token}})
+		if code != http.StatusForbidden || !strings.Contains(body, "Wrong password") {
+			t.Errorf("wrong password: got status %d; want %d and the form again", code, http.StatusForbidden)
+		}
+
+		code, header, _ := ts.postForm(t, "/snippet/"+id+"/unlock", url.Values{"password": {"open sesame"}, csrfField: {token}})
+		if code != http.StatusSeeOther || header.Get("Location") != "/snippet/"+id {
+			t.Fatalf("right password: got status %d to %q; want %d to /snippet/%s", code, header.Get("Location"), http.StatusSeeOther, id)
+		}
+
+		// The session now opens the snippet in every format.
+		for _, path := range []string{"/snippet/" + id, "/snippet/" + id + "/raw", "/snippet/" + id + "/download"} {
+			code, _, body := ts.get(t, path)
+			if code != http.StatusOK || !strings.Contains(body, content) {
+				t.Errorf("%s: got status %d; want %d with the content", path, code, http.StatusOK)
+			}
+		}
+	})
+}
+
+// TestUnlockedSnippetsCap checks that a session remembers only the most
+// recently unlocked snippets.
+func TestUnlockedSnippetsCap(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Unlocking for real takes a bcrypt comparison per snippet, so the
+	// session is filled up directly first.
+	seeded := make([]int, maxUnlockedSnippets)
+	for i := range seeded {
+		seeded[i] = 1000 + i
+	}
+	var got []int
+
+	mux := http.NewServeMux()
+	mux.Handle("/", app.routes())
+	mux.Handle("/seed", app.session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.session.Put(r.Context(), unlockedSessionKey, seeded)
+	})))
+	mux.Handle("/unlocked", app.session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = app.unlockedSnippets(r)
+	})))
+	ts := newTestServer(t, mux)
+
+	id := insertProtected(t, app, "Locked", "Content", "open sesame")
+
+	ts.get(t, "/seed")
+	_, _, body := ts.get(t, "/snippet/"+strconv.Itoa(id))
+	form := url.Values{"password": {"open sesame"}, csrfField: {extractCSRFToken(t, body)}}
+	if code, _, _ := ts.postForm(t, "/snippet/"+strconv.Itoa(id)+"/unlock", form); code != http.StatusSeeOther {
+		t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+	}
+	ts.get(t, "/unlocked")
+
+	if len(got) != maxUnlockedSnippets {
+		t.Fatalf("got %d unlocked snippets; want %d", len(got), maxUnlockedSnippets)
+	}
+	if got[0] != seeded[1] || got[len(got)-1] != id {
+		t.Errorf("got %d to %d; want %d to %d", got[0], got[len(got)-1], seeded[1], id)
+	}
+}
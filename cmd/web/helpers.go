@@ -4,8 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gbih/snippetbox/pkg/forms"
+	"github.com/gbih/snippetbox/pkg/models"
 )
 
 // GB: Good example of mutating a field of an instance of a struct.
@@ -25,6 +31,14 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	// data this will return the empty string.
 	td.Flash = session.PopString(r.Context(), "flash")
 
+	// Let the templates show or hide navigation items depending on whether
+	// the user is logged in.
+	td.AuthenticatedUser = app.authenticatedUser(r)
+
+	// Every form that posts back to us has to include this.
+	td.csrfToken = func() (string, error) { return app.csrfToken(r) }
+	td.CSPNonce = cspNonce(r)
+
 	return td
 }
 
@@ -42,7 +56,106 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// authenticatedUser returns the user added to the request context by the
+// authenticate middleware, or nil for anonymous requests.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(contextKeyUser).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+func (app *application) isAuthenticated(r *http.Request) bool {
+	return app.authenticatedUser(r) != nil
+}
+
+// canModify reports whether the current user may edit or delete s: admins
+// can change any snippet, everyone else only the snippets they own.
+func (app *application) canModify(r *http.Request, s *models.Snippet) bool {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false
+	}
+	return user.Admin || (s.UserID != 0 && s.UserID == user.ID)
+}
+
+// listOptions validates the listing query parameters shared by /snippets and
+// /api/v1/snippets (sort, order, limit, cursor, from, to, tag) and converts
+// them to models.ListOptions. Problems are reported on the returned form.
+func listOptions(q url.Values) (models.ListOptions, *forms.Form) {
+	form := forms.New(q)
+	form.PermittedValues("sort", "created", "expires", "title")
+	form.PermittedValues("order", "asc", "desc")
+
+	opts := models.ListOptions{
+		Sort:   form.Get("sort"),
+		Asc:    form.Get("order") == "asc",
+		Cursor: form.Get("cursor"),
+	}
+
+	if v := form.Get("tag"); v != "" {
+		tags, err := models.NormalizeTags([]string{v})
+		if err != nil {
+			form.Errors.Add("tag", "This field is invalid")
+		} else {
+			opts.Tag = tags[0]
+		}
+	}
+
+	if v := form.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			form.Errors.Add("limit", fmt.Sprintf("This field must be a number between 1 and %d", models.MaxPageSize))
+		}
+		opts.Limit = n
+	}
+
+	// Dates come from <input type='date'>; "to" is inclusive, so the range
+	// ends at the start of the following day.
+	if v := form.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("from", "This field must be a date (YYYY-MM-DD)")
+		}
+		opts.CreatedFrom = t
+	}
+	if v := form.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("to", "This field must be a date (YYYY-MM-DD)")
+		} else {
+			opts.CreatedTo = t.AddDate(0, 0, 1)
+		}
+	}
+
+	return opts, form
+}
+
+// pageURL returns the listing URL for the page at cursor, keeping the other
+// query parameters. It returns "" when there is no such page.
+func pageURL(path string, q url.Values, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	v := url.Values{}
+	for k, vs := range q {
+		// Skip the ":name" style parameters pat adds for route captures.
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		v[k] = vs
+	}
+	v.Set("cursor", cursor)
+	return path + "?" + v.Encode()
+}
+
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderStatus(w, r, http.StatusOK, name, td)
+}
+
+// renderStatus is render with a status code other than 200 OK.
+func (app *application) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, td *templateData) {
 	ts, ok := app.templateCache[name]
 	if !ok {
 		app.serverError(w, fmt.Errorf("The template %s does not exist", name))
@@ -73,6 +186,7 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	// Write the contents of the buffer to the http.ResponseWriter. Again, this
 	// is another time where we pass our http.ResponseWriter to a function that
 	// takes an io.Writer.
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 
 }
@@ -1,20 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-)
+	"strconv"
+	"strings"
+	"time"
 
-// Set up the "middleware-constructor" boilerplate
-func secureHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Actual logic
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("X-Frame-Options", "deny")
-		// control flow mechanism via calling successive handler
-		next.ServeHTTP(w, r)
-	})
-}
+	"github.com/gbih/snippetbox/pkg/models"
+	"github.com/gbih/snippetbox/pkg/ratelimit"
+)
 
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -34,3 +32,196 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticate looks up the user ID stored in the session, and if it belongs
+// to an existing, active user, adds that user to the request context. It must
+// run after session.LoadAndSave.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.session.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.users.Get(id)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && !user.Active) {
+			// The account has gone away or been deactivated since login.
+			app.session.Remove(r.Context(), "authenticatedUserID")
+			next.ServeHTTP(w, r)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAuthentication redirects anonymous users to the login page,
+// remembering where they were headed so login can send them back there.
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			if r.Method == http.MethodGet {
+				app.session.Put(r.Context(), "redirectPathAfterLogin", r.URL.Path)
+			}
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// Pages that require authentication shouldn't be stored in caches
+		// (browser cache or other intermediary cache).
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken is the API counterpart of authenticate. A request with an
+// "Authorization: Bearer <token>" header is attributed to the token's owner;
+// a bad token is rejected outright rather than treated as anonymous, so
+// clients find out straight away. Requests without the header pass through.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Any API response that depends on the caller must not be shared
+		// by caches.
+		w.Header().Add("Vary", "Authorization")
+
+		parts := strings.Fields(header)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			app.apiUnauthorized(w, r, codeInvalidToken, "The Authorization header must have the form \"Bearer <token>\".")
+			return
+		}
+
+		token, err := app.tokens.Authenticate(parts[1])
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.apiUnauthorized(w, r, codeInvalidToken, "The token is invalid or has been revoked.")
+			return
+		} else if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+
+		user, err := app.users.Get(token.UserID)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && !user.Active) {
+			app.apiUnauthorized(w, r, codeInvalidToken, "The token is invalid or has been revoked.")
+			return
+		} else if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		ctx = context.WithValue(ctx, contextKeyToken, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope rejects API requests that weren't made with a token granting
+// scope: 401 without a token, 403 with one that's too weak.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(contextKeyToken).(*models.Token)
+			if !ok {
+				app.apiUnauthorized(w, r, codeUnauthorized, "This request needs an API token.")
+				return
+			}
+			if !token.Allows(scope) {
+				app.apiProblem(w, r, http.StatusForbidden, codeInsufficientScope, fmt.Sprintf("This request needs a token with the %q scope.", scope))
+				return
+			}
+
+			w.Header().Add("Cache-Control", "no-store")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireAdmin rejects API requests from users who aren't administrators. It
+// goes after requireScope, which has already made sure there is a user.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.authenticatedUser(r)
+		if user == nil {
+			app.apiUnauthorized(w, r, codeUnauthorized, "This request needs an API token.")
+			return
+		}
+		if !user.Admin {
+			app.apiProblem(w, r, http.StatusForbidden, codeForbidden, "This request is only open to administrators.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit returns middleware enforcing limit separately for each API token
+// or, for requests without one, each client IP address. It must come after
+// authenticateToken. Every response carries RateLimit-* headers describing
+// the client's bucket; refused requests get a 429 with Retry-After. A
+// disabled limit passes requests straight through.
+func (app *application) rateLimit(limit ratelimit.Limit) func(http.Handler) http.Handler {
+	if !limit.Enabled() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	limiter := ratelimit.New(limit)
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, seconds(limit.Period))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Allow(rateLimitKey(r))
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+			h.Set("RateLimit-Policy", policy)
+
+			if !res.Allowed {
+				retry := seconds(res.RetryAfter)
+				h.Set("Retry-After", strconv.Itoa(retry))
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					app.apiProblem(w, r, http.StatusTooManyRequests, codeRateLimited,
+						fmt.Sprintf("Too many requests. Try again in %d seconds.", retry))
+				} else {
+					app.clientError(w, http.StatusTooManyRequests)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the client a request is counted against. Behind a
+// reverse proxy every request comes from the proxy's address, so limits
+// keyed by IP then apply to all anonymous clients together.
+func rateLimitKey(r *http.Request) string {
+	if token, ok := r.Context().Value(contextKeyToken).(*models.Token); ok {
+		return "token:" + strconv.Itoa(token.ID)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds d up to whole seconds, as the rate limit headers want.
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestAPITokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.users.Insert("Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	read, err := app.tokens.Insert(1, "read", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write, err := app.tokens.Insert(1, "write", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := app.tokens.Insert(1, "revoked", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.tokens.Revoke(1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	const body = `{"title": "Title", "content": "Content", "expires_days": 7}`
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		wantCode      int
+		wantProblem   string // the problem code, for errors
+	}{
+		{"Anonymous read", http.MethodGet, "", http.StatusOK, ""},
+		{"Anonymous write", http.MethodPost, "", http.StatusUnauthorized, codeUnauthorized},
+		{"Not a bearer token", http.MethodGet, "Basic " + read, http.StatusUnauthorized, codeInvalidToken},
+		{"Extra words", http.MethodGet, "Bearer " + read + " x", http.StatusUnauthorized, codeInvalidToken},
+		{"Unknown token", http.MethodGet, "Bearer sbx_unknown", http.StatusUnauthorized, codeInvalidToken},
+		{"Revoked token", http.MethodGet, "Bearer " + revoked, http.StatusUnauthorized, codeInvalidToken},
+		{"Read token reading", http.MethodGet, "Bearer " + read, http.StatusOK, ""},
+		{"Read token writing", http.MethodPost, "Bearer " + read, http.StatusForbidden, codeInsufficientScope},
+		{"Write token reading", http.MethodGet, "bearer " + write, http.StatusOK, ""},
+		{"Write token writing", http.MethodPost, "Bearer " + write, http.StatusCreated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {"application/json"}}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+			code, header, respBody := ts.do(t, tt.method, "/api/v1/snippets", header, body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d: %s", code, tt.wantCode, respBody)
+			}
+			if tt.wantProblem != "" && !strings.Contains(respBody, `"code": "`+tt.wantProblem+`"`) {
+				t.Errorf("body doesn't contain the code %q: %s", tt.wantProblem, respBody)
+			}
+			if code == http.StatusUnauthorized && header.Get("WWW-Authenticate") == "" {
+				t.Error("no WWW-Authenticate on a 401")
+			}
+			if tt.authorization != "" && !contains(header.Values("Vary"), "Authorization") {
+				t.Errorf("got Vary %q; want Authorization among them", header.Values("Vary"))
+			}
+		})
+	}
+
+	t.Run("Last use recorded", func(t *testing.T) {
+		tokens, err := app.tokens.List(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range tokens {
+			if token.LastUsed.IsZero() {
+				t.Errorf("the %s token has no last use", token.Name)
+			}
+		}
+	})
+}
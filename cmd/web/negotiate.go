@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Representations a negotiated handler can produce, in order of preference
+// when the client doesn't mind.
+const (
+	formatHTML = "text/html"
+	formatJSON = "application/json"
+	formatText = "text/plain"
+)
+
+var formats = []string{formatHTML, formatJSON, formatText}
+
+// negotiate picks the representation to send for the request's Accept
+// header, following RFC 7231: each format gets the weight of the most
+// specific media range matching it, and ties go to the server's preference.
+// No Accept header means anything goes. If none of the formats is
+// acceptable it answers 406 itself and returns "".
+func (app *application) negotiate(w http.ResponseWriter, r *http.Request) string {
+	w.Header().Add("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formats[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, f := range formats {
+		if q := acceptWeight(ranges, f); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+
+	if best == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusNotAcceptable)
+		fmt.Fprintf(w, "%s\nAvailable: %s\n", http.StatusText(http.StatusNotAcceptable), strings.Join(formats, ", "))
+	}
+	return best
+}
+
+// mediaRange is one element of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		i := strings.Index(mt, "/")
+		if i < 1 || i == len(mt)-1 {
+			continue
+		}
+
+		mr := mediaRange{typ: mt[:i], subtype: mt[i+1:], q: 1}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && strings.EqualFold(p[:2], "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q >= 0 && q <= 1 {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// acceptWeight returns the q-value the client gives mediaType, 0 if it
+// isn't acceptable.
+func acceptWeight(ranges []mediaRange, mediaType string) float64 {
+	i := strings.Index(mediaType, "/")
+	typ, subtype := mediaType[:i], mediaType[i+1:]
+
+	q, specificity := 0.0, 0
+	for _, mr := range ranges {
+		s := 0
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 3
+		case mr.typ == typ && mr.subtype == "*":
+			s = 2
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// acceptJSON makes a negotiated handler always answer with JSON, for the
+// /api/v1 aliases of the HTML pages.
+func acceptJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Accept", formatJSON)
+		next(w, r)
+	}
+}
+
+// notFoundAs and serverErrorAs report errors from a negotiated handler in
+// the format the client asked for.
+
+func (app *application) notFoundAs(w http.ResponseWriter, r *http.Request, format string) {
+	if format == formatJSON {
+		app.apiNotFound(w, r)
+		return
+	}
+	app.notFound(w)
+}
+
+func (app *application) serverErrorAs(w http.ResponseWriter, r *http.Request, format string, err error) {
+	if format == formatJSON {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.serverError(w, err)
+}
+
+// writeText sends a plain-text representation.
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, text)
+}
+
+// snippetText is the plain-text representation of a snippet.
+func snippetText(s *models.Snippet) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", s.Label(), s.Title)
+	if len(s.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n", strings.Join(s.Tags, ", "))
+	}
+	fmt.Fprintf(&b, "Created: %s\nExpires: %s\n\n", humanDate(s.Created), humanDate(s.Expires))
+	b.WriteString(s.Content)
+	if !strings.HasSuffix(s.Content, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// listedSnippetText is snippetText for listings, which leave out the content
+// of password-protected snippets.
+func listedSnippetText(s *models.Snippet) string {
+	if !s.Protected() {
+		return snippetText(s)
+	}
+	locked := *s
+	locked.Content = "(password-protected)"
+	return snippetText(&locked)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []mediaRange
+	}{
+		{
+			name:   "Single",
+			header: "text/html",
+			want:   []mediaRange{{"text", "html", 1}},
+		},
+		{
+			name:   "Browser",
+			header: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			want: []mediaRange{
+				{"text", "html", 1},
+				{"application", "xhtml+xml", 1},
+				{"application", "xml", 0.9},
+				{"*", "*", 0.8},
+			},
+		},
+		{
+			name:   "Case and spaces",
+			header: " Application/JSON ; Q=0.5 , TEXT/*;charset=utf-8",
+			want:   []mediaRange{{"application", "json", 0.5}, {"text", "*", 1}},
+		},
+		{
+			name:   "q of zero",
+			header: "text/html;q=0",
+			want:   []mediaRange{{"text", "html", 0}},
+		},
+		{
+			name:   "q with other parameters",
+			header: "text/plain;format=flowed;q=0.3;level=1",
+			want:   []mediaRange{{"text", "plain", 0.3}},
+		},
+		{
+			name:   "Bad q-values ignored",
+			header: "text/html;q=abc, text/plain;q=1.5, application/json;q=-0.1, text/css;q=, text/csv;q=NaN",
+			want: []mediaRange{
+				{"text", "html", 1},
+				{"text", "plain", 1},
+				{"application", "json", 1},
+				{"text", "css", 1},
+				{"text", "csv", 1},
+			},
+		},
+		{
+			name:   "Malformed ranges skipped",
+			header: "html, /json, text/, ,;q=1, application/json",
+			want:   []mediaRange{{"application", "json", 1}},
+		},
+		{
+			name:   "Nothing usable",
+			header: ",,,",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseAccept(tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptWeight(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		mediaType string
+		want      float64
+	}{
+		{"Exact", "application/json", formatJSON, 1},
+		{"Not listed", "application/json", formatHTML, 0},
+		{"Subtype wildcard", "text/*;q=0.4", formatText, 0.4},
+		{"Subtype wildcard other type", "text/*", formatJSON, 0},
+		{"Full wildcard", "*/*;q=0.1", formatJSON, 0.1},
+		{"Exact beats wildcard", "*/*;q=0.9, text/html;q=0.2", formatHTML, 0.2},
+		{"Subtype wildcard beats full wildcard", "*/*, text/*;q=0.3", formatText, 0.3},
+		{"Exact beats subtype wildcard whatever the order", "text/plain;q=0.7, text/*;q=0.1", formatText, 0.7},
+		{"Excluded by q=0", "*/*, text/html;q=0", formatHTML, 0},
+		{"Type wildcard only is not a range", "*/html", formatHTML, 0},
+		{"Bad q-value counts as 1", "application/json;q=high", formatJSON, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := acceptWeight(parseAccept(tt.header), tt.mediaType)
+			if got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		accept   string
+		want     string
+		wantCode int
+	}{
+		{"No Accept header", "", formatHTML, http.StatusOK},
+		{"Anything", "*/*", formatHTML, http.StatusOK},
+		{"JSON", "application/json", formatJSON, http.StatusOK},
+		{"Text preferred", "text/html;q=0.5, text/plain", formatText, http.StatusOK},
+		{"Tie goes to the server", "application/json, text/plain", formatJSON, http.StatusOK},
+		{"Everything but HTML", "text/html;q=0, */*", formatJSON, http.StatusOK},
+		{"Nothing acceptable", "image/png", "", http.StatusNotAcceptable},
+		{"Garbage", "not a media type", "", http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			got := app.negotiate(rr, r)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+			if rr.Code != tt.wantCode {
+				t.Errorf("got status %d; want %d", rr.Code, tt.wantCode)
+			}
+			if v := rr.Header().Get("Vary"); v != "Accept" {
+				t.Errorf("got Vary %q; want Accept", v)
+			}
+		})
+	}
+}
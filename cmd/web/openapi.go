@@ -0,0 +1,578 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// The JSON API is described by a single route table. routes registers the
+// handlers from it and the OpenAPI document served at /api/v1/openapi.json
+// is generated from it, with schemas derived from the very types the
+// handlers encode and decode, so the two can't drift apart.
+
+// apiRoute is one operation of the JSON API.
+type apiRoute struct {
+	Method       string
+	Pattern      string // pat pattern, e.g. /api/v1/snippets/:id
+	Handler      http.HandlerFunc
+	ID           string // OpenAPI operationId
+	Summary      string
+	Scope        string // token scope required, "" if the route is public
+	Admin        bool   // only administrators may use it
+	BodyType     string // media type of Body, if not application/json
+	ResponseType string // media type of Response, if not application/json
+	Params       []*openAPIParameter
+	Body         interface{} // zero value of the request body type, nil if none
+	Status       int         // status of a successful response
+	Response     interface{} // zero value of the response body type, nil if none
+	Errors       []int       // error statuses besides 401, 403, 429 and 500
+	Conditional  bool        // supports If-None-Match (GET) or If-Match (writes)
+	Deprecated   bool
+	Description  string
+}
+
+// apiVersion is the version reported in the OpenAPI document.
+const apiVersion = "1.0.0"
+
+func (app *application) apiRoutes() []*apiRoute {
+	listParams := []*openAPIParameter{
+		queryParam("sort", "Field to sort by.", false, &jsonSchema{Type: "string", Enum: []string{"created", "expires", "title"}}),
+		queryParam("order", "Sort order.", false, &jsonSchema{Type: "string", Enum: []string{"asc", "desc"}}),
+		queryParam("limit", "Page size.", false, &jsonSchema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(models.MaxPageSize)}),
+		queryParam("cursor", "The next or prev cursor of a previous page.", false, &jsonSchema{Type: "string"}),
+		queryParam("tag", "Only list snippets with this tag.", false, &jsonSchema{Type: "string"}),
+		queryParam("from", "Only list snippets created on or after this day.", false, &jsonSchema{Type: "string", Format: "date"}),
+		queryParam("to", "Only list snippets created on or before this day.", false, &jsonSchema{Type: "string", Format: "date"}),
+	}
+
+	passwordParam := &openAPIParameter{
+		Name: snippetPasswordHeader, In: "header", Schema: &jsonSchema{Type: "string"},
+		Description: "The password of a password-protected snippet. Wrong passwords are rate limited per snippet.",
+	}
+
+	return []*apiRoute{
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/snippets", Handler: app.apiListSnippets,
+			ID: "listSnippets", Summary: "List snippets",
+			Description: "Lists unexpired public snippets a page at a time. Follow the next and prev cursors to page through them. " +
+				"Password-protected snippets are listed without their content.",
+			Params: listParams,
+			Status: http.StatusOK, Response: &snippetListJSON{},
+			Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodPost, Pattern: "/api/v1/snippets", Handler: app.apiCreateSnippet,
+			ID: "createSnippet", Summary: "Create a snippet", Scope: models.ScopeWrite,
+			Description: "Creates a snippet owned by the token's user. Every field except tags, visibility and password is required.",
+			Body:        &snippetInput{},
+			Status:      http.StatusCreated, Response: &snippetJSON{},
+			Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/snippets/:id", Handler: app.apiGetSnippet,
+			ID: "getSnippet", Summary: "Get a snippet", Conditional: true,
+			Description: "A password-protected snippet needs its password, unless the token's user wrote it or is an administrator.",
+			Params:      []*openAPIParameter{passwordParam},
+			Status:      http.StatusOK, Response: &snippetJSON{},
+			Errors: []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPut, Pattern: "/api/v1/snippets/:id", Handler: app.apiUpdateSnippet,
+			ID: "replaceSnippet", Summary: "Replace a snippet", Scope: models.ScopeWrite, Conditional: true,
+			Description: "Replaces a snippet. Every field except tags and visibility is required; without visibility it is left alone. " +
+				"The password can't be changed.",
+			Body:   &snippetInput{},
+			Status: http.StatusOK, Response: &snippetJSON{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodPatch, Pattern: "/api/v1/snippets/:id", Handler: app.apiUpdateSnippet,
+			ID: "updateSnippet", Summary: "Update a snippet", Scope: models.ScopeWrite, Conditional: true,
+			Description: "Changes only the fields present. Without expires_days the expiry is left alone. " +
+				"A snippet that stops being public gets a new slug.",
+			Body:   &snippetInput{},
+			Status: http.StatusOK, Response: &snippetJSON{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/api/v1/snippets/:id", Handler: app.apiDeleteSnippet,
+			ID: "deleteSnippet", Summary: "Delete a snippet", Scope: models.ScopeWrite, Conditional: true,
+			Status: http.StatusNoContent,
+			Errors: []int{http.StatusNotFound, http.StatusPreconditionFailed},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/search", Handler: app.apiSearchSnippets,
+			ID: "searchSnippets", Summary: "Search snippets",
+			Description: "Full-text search over titles and contents, best matches first. Password-protected snippets are left out.",
+			Params: []*openAPIParameter{
+				queryParam("q", "Search query.", true, &jsonSchema{Type: "string"}),
+				queryParam("page", "Page number.", false, &jsonSchema{Type: "integer", Minimum: intPtr(1)}),
+			},
+			Status: http.StatusOK, Response: &searchPageJSON{},
+			Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/tags", Handler: app.apiListTags,
+			ID: "listTags", Summary: "List tags",
+			Description: "Lists every tag on an unexpired snippet with the number of snippets carrying it.",
+			Status:      http.StatusOK, Response: []*tagJSON{},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/export", Handler: app.apiExportSnippets,
+			ID: "exportSnippets", Summary: "Export snippets", Scope: models.ScopeRead, Admin: true,
+			Description: "Streams every unexpired snippet matching the same filters as listSnippets, one JSON object per line, " +
+				"including unlisted, private and password-protected snippets, the last with their password hashes. " +
+				"The limit and cursor parameters are ignored.",
+			Params: listParams,
+			Status: http.StatusOK, Response: &exportLine{}, ResponseType: ndjsonType,
+			Errors: []int{http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodPost, Pattern: "/api/v1/import", Handler: app.apiImportSnippets,
+			ID: "importSnippets", Summary: "Import snippets", Scope: models.ScopeWrite, Admin: true,
+			Description: "Creates a snippet for each line of the body, which may be the output of exportSnippets. " +
+				"Nothing is imported unless every line is valid; the problem lists the errors by line number. " +
+				"Imported snippets get new IDs and slugs and belong to the importing user.",
+			Params: []*openAPIParameter{
+				queryParam("dry_run", "Only check the body.", false, &jsonSchema{Type: "boolean"}),
+			},
+			Body: &importLine{}, BodyType: ndjsonType,
+			Status: http.StatusOK, Response: &importReport{},
+			Errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/openapi.json", Handler: app.apiDocument,
+			ID: "getOpenAPIDocument", Summary: "Get this OpenAPI document",
+			Status: http.StatusOK, Response: map[string]interface{}{},
+		},
+
+		// Early experiments, kept for existing clients. The first two are
+		// aliases for the JSON representations of /snippet/:id and /; the
+		// first needs the session, where showSnippet finds the snippets the
+		// browser has unlocked.
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/snippet", Handler: app.session.LoadAndSave(acceptJSON(app.showSnippet)).ServeHTTP,
+			ID: "legacyGetSnippet", Summary: "Get a snippet by query parameter", Deprecated: true,
+			Description: "Use GET /api/v1/snippets/{id}, or GET /snippet/{id} with Accept: application/json, instead.",
+			Params: []*openAPIParameter{
+				queryParam("id", "Snippet ID or slug.", true, &jsonSchema{Type: "string"}),
+				passwordParam,
+			},
+			Status: http.StatusOK, Response: &snippetJSON{},
+			Errors: []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/home", Handler: acceptJSON(app.home),
+			ID: "legacyLatestSnippets", Summary: "Latest snippets", Deprecated: true,
+			Description: "Use GET /api/v1/snippets, or GET / with Accept: application/json, instead.",
+			Status:      http.StatusOK, Response: []*snippetJSON{},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/api/v1/test", Handler: app.apiTest,
+			ID: "legacyTest", Summary: "Test data", Deprecated: true,
+			Status: http.StatusOK, Response: []apiTestPerson{},
+		},
+	}
+}
+
+// API Usage: curl -i localhost:4000/api/v1/openapi.json
+
+func (app *application) apiDocument(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, newOpenAPIDoc(app.apiRoutes()))
+}
+
+// apiReference renders the document as a human-readable page.
+func (app *application) apiReference(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "api.page.html", &templateData{
+		API: newOpenAPIDoc(app.apiRoutes()),
+	})
+}
+
+//----------
+
+// The types below are the subset of OpenAPI 3.0 the API needs.
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"` // path -> lower-case method -> operation
+	Components openAPIComponents                       `json:"components"`
+	pathOrder  []string                                // paths in route-table order
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*jsonSchema     `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security"`
+
+	Method string `json:"-"` // upper-case, for the reference page
+	Path   string `json:"-"`
+}
+
+type openAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Maximum              *int                   `json:"maximum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+}
+
+// String describes the schema in a few words, e.g. "array of Snippet".
+func (s *jsonSchema) String() string {
+	switch {
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, schemaRefPrefix)
+	case s.Type == "array" && s.Items != nil:
+		return "array of " + s.Items.String()
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	case len(s.Enum) > 0:
+		return s.Type + ": " + strings.Join(s.Enum, ", ")
+	}
+	return s.Type
+}
+
+// Fields lists the properties in name order, for the reference page.
+func (s *jsonSchema) Fields() []*schemaField {
+	var fields []*schemaField
+	for name, p := range s.Properties {
+		required := false
+		for _, r := range s.Required {
+			required = required || r == name
+		}
+		fields = append(fields, &schemaField{name, p, required})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+type schemaField struct {
+	Name     string
+	Schema   *jsonSchema
+	Required bool
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// schemaNames names the types that appear in the document as components.
+// Structs not listed here are described inline.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(snippetJSON{}):      "Snippet",
+	reflect.TypeOf(snippetListJSON{}):  "SnippetList",
+	reflect.TypeOf(snippetInput{}):     "SnippetInput",
+	reflect.TypeOf(searchPageJSON{}):   "SearchResults",
+	reflect.TypeOf(searchResultJSON{}): "SearchResult",
+	reflect.TypeOf(tagJSON{}):          "Tag",
+	reflect.TypeOf(importLine{}):       "ImportLine",
+	reflect.TypeOf(importReport{}):     "ImportReport",
+	reflect.TypeOf(problem{}):          "Problem",
+	reflect.TypeOf(apiTestPerson{}):    "TestPerson",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// patParamRX matches the :name parameters in a pat pattern.
+var patParamRX = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// newOpenAPIDoc builds the document for routes.
+func newOpenAPIDoc(routes []*apiRoute) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   "Snippetbox API",
+			Version: apiVersion,
+			Description: "Reading snippets needs no credentials. Creating, changing and deleting them needs a personal " +
+				"API token with write scope, sent as a bearer token. Errors are RFC 7807 problem details.",
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*jsonSchema{},
+			SecuritySchemes: map[string]*securityScheme{
+				"bearerAuth": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "A personal API token (sbx_...) created on the API tokens page.",
+				},
+			},
+		},
+	}
+
+	problemSchema := doc.schema(reflect.TypeOf(problem{}))
+
+	for _, rt := range routes {
+		path := patParamRX.ReplaceAllString(rt.Pattern, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+			doc.pathOrder = append(doc.pathOrder, path)
+		}
+
+		op := &openAPIOperation{
+			OperationID: rt.ID,
+			Summary:     rt.Summary,
+			Description: rt.Description,
+			Deprecated:  rt.Deprecated,
+			Responses:   map[string]*openAPIResponse{},
+			Method:      rt.Method,
+			Path:        path,
+		}
+
+		for _, m := range patParamRX.FindAllStringSubmatch(rt.Pattern, -1) {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:        m[1],
+				In:          "path",
+				Required:    true,
+				Description: "The ID of a public snippet, or the slug of an unlisted or private one.",
+				Schema:      &jsonSchema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, rt.Params...)
+
+		if rt.Conditional && rt.Method == http.MethodGet {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name: "If-None-Match", In: "header", Schema: &jsonSchema{Type: "string"},
+				Description: "ETag of a cached copy; answered with 304 if it is still current.",
+			})
+			op.Responses[strconv.Itoa(http.StatusNotModified)] = &openAPIResponse{Description: http.StatusText(http.StatusNotModified)}
+		} else if rt.Conditional {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name: "If-Match", In: "header", Schema: &jsonSchema{Type: "string"},
+				Description: "ETag of the snippet as last fetched; answered with 412 if it has changed since.",
+			})
+		}
+
+		if rt.Body != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  jsonContent(orDefault(rt.BodyType, "application/json"), doc.schema(reflect.TypeOf(rt.Body))),
+			}
+		}
+
+		success := &openAPIResponse{Description: http.StatusText(rt.Status)}
+		if rt.Response != nil {
+			success.Content = jsonContent(orDefault(rt.ResponseType, "application/json"), doc.schema(reflect.TypeOf(rt.Response)))
+		}
+		op.Responses[strconv.Itoa(rt.Status)] = success
+
+		// authenticateToken rejects a bad token and rateLimit a client
+		// making too many requests on every route.
+		errs := append([]int{http.StatusUnauthorized, http.StatusTooManyRequests}, rt.Errors...)
+		if rt.Scope != "" {
+			errs = append(errs, http.StatusForbidden)
+		}
+		for _, status := range errs {
+			op.Responses[strconv.Itoa(status)] = &openAPIResponse{
+				Description: http.StatusText(status),
+				Content:     jsonContent("application/problem+json", problemSchema),
+			}
+		}
+		op.Responses["default"] = &openAPIResponse{
+			Description: "Error",
+			Content:     jsonContent("application/problem+json", problemSchema),
+		}
+
+		// The empty requirement makes the token optional on public routes.
+		if rt.Scope != "" {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			op.Description = strings.TrimSpace(op.Description + " Needs a token with " + rt.Scope + " scope.")
+			if rt.Admin {
+				op.Description += " Only administrators may use it."
+			}
+		} else {
+			op.Security = []map[string][]string{{}, {"bearerAuth": {}}}
+		}
+
+		doc.Paths[path][strings.ToLower(rt.Method)] = op
+	}
+
+	return doc
+}
+
+// Operations lists the operations in route-table order, for the reference
+// page.
+func (doc *openAPIDoc) Operations() []*openAPIOperation {
+	var ops []*openAPIOperation
+	for _, path := range doc.pathOrder {
+		for _, m := range []string{"get", "post", "put", "patch", "delete"} {
+			if op, ok := doc.Paths[path][m]; ok {
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+// SchemaNames lists the component schemas in name order.
+func (doc *openAPIDoc) SchemaNames() []string {
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// schema returns the schema for values of type t as encoding/json would
+// marshal them, adding named structs to the document's components.
+func (doc *openAPIDoc) schema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		name, ok := schemaNames[t]
+		if !ok {
+			return doc.structSchema(t)
+		}
+		if _, done := doc.Components.Schemas[name]; !done {
+			// Reserve the name first in case the type refers to itself.
+			doc.Components.Schemas[name] = nil
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &jsonSchema{Ref: schemaRefPrefix + name}
+	}
+
+	// interface{} and anything else: any value.
+	return &jsonSchema{}
+}
+
+// structSchema describes a struct's exported fields, following the json
+// struct tags and flattening embedded structs the way encoding/json does.
+func (doc *openAPIDoc) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := doc.structSchema(ft)
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = doc.schema(f.Type)
+
+		// Pointers mark optional input fields; omitempty optional output.
+		if f.Type.Kind() != reflect.Ptr && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	sort.Strings(s.Required)
+	return s
+}
+
+func jsonContent(mediaType string, s *jsonSchema) map[string]*openAPIMediaType {
+	return map[string]*openAPIMediaType{mediaType: {Schema: s}}
+}
+
+func queryParam(name, description string, required bool, s *jsonSchema) *openAPIParameter {
+	return &openAPIParameter{Name: name, In: "query", Description: description, Required: required, Schema: s}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func intPtr(n int) *int {
+	return &n
+}
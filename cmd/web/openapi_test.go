@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var schemaRefRX = regexp.MustCompile(`"\$ref":\s*"` + schemaRefPrefix + `([^"]+)"`)
+
+// TestOpenAPIDocument checks the served document against the route table:
+// every route is documented, nothing else is, and every schema reference
+// resolves.
+func TestOpenAPIDocument(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.get(t, "/api/v1/openapi.json")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", code, http.StatusOK)
+	}
+	if got := header.Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("got Content-Type %q; want JSON", got)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("got openapi %q; want 3.0.3", doc.OpenAPI)
+	}
+
+	routes := app.apiRoutes()
+	ids := map[string]bool{}
+
+	for _, rt := range routes {
+		path := patParamRX.ReplaceAllString(rt.Pattern, "{$1}")
+		op, ok := doc.Paths[path][strings.ToLower(rt.Method)]
+		if !ok {
+			t.Errorf("%s %s isn't documented", rt.Method, path)
+			continue
+		}
+		if op.OperationID != rt.ID {
+			t.Errorf("%s %s: got operationId %q; want %q", rt.Method, path, op.OperationID, rt.ID)
+		}
+		if ids[rt.ID] {
+			t.Errorf("operationId %q is used twice", rt.ID)
+		}
+		ids[rt.ID] = true
+
+		for _, m := range patParamRX.FindAllStringSubmatch(rt.Pattern, -1) {
+			found := false
+			for _, p := range op.Parameters {
+				found = found || (p.In == "path" && p.Name == m[1])
+			}
+			if !found {
+				t.Errorf("%s %s: the path parameter %q isn't documented", rt.Method, path, m[1])
+			}
+		}
+
+		if _, ok := op.Responses["default"]; !ok {
+			t.Errorf("%s %s: no default response", rt.Method, path)
+		}
+	}
+
+	n := 0
+	for _, ops := range doc.Paths {
+		n += len(ops)
+	}
+	if n != len(routes) {
+		t.Errorf("got %d operations; want one for each of the %d routes", n, len(routes))
+	}
+
+	refs := schemaRefRX.FindAllStringSubmatch(body, -1)
+	if len(refs) == 0 {
+		t.Error("no schema references found")
+	}
+	for _, ref := range refs {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("the reference to %q doesn't resolve", ref[1])
+		}
+	}
+
+	// The request body schema lists exactly the fields readJSON accepts.
+	input := doc.Components.Schemas["SnippetInput"].Properties
+	it := reflect.TypeOf(snippetInput{})
+	if len(input) != it.NumField() {
+		t.Errorf("got %d SnippetInput properties; want %d", len(input), it.NumField())
+	}
+	for i := 0; i < it.NumField(); i++ {
+		name := strings.Split(it.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := input[name]; !ok {
+			t.Errorf("SnippetInput doesn't document %q", name)
+		}
+	}
+}
+
+// TestAPIRoutesServed checks the other direction: every documented
+// operation reaches the API middleware chain rather than the HTML 404 or a
+// 405.
+func TestAPIRoutesServed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for _, rt := range app.apiRoutes() {
+		path := patParamRX.ReplaceAllString(rt.Pattern, "1")
+		t.Run(rt.Method+" "+path, func(t *testing.T) {
+			code, header, _ := ts.do(t, rt.Method, path, nil, "")
+			if code == http.StatusMethodNotAllowed {
+				t.Fatalf("got status %d", code)
+			}
+			if got := header.Get("Content-Type"); !strings.HasPrefix(got, "application/") {
+				t.Errorf("got status %d with Content-Type %q; want an API response", code, got)
+			}
+		})
+	}
+}
+
+func TestAPIReference(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, _, body := ts.get(t, "/api/v1/docs")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d", code, http.StatusOK)
+	}
+	for _, rt := range app.apiRoutes() {
+		if !strings.Contains(body, rt.Summary) {
+			t.Errorf("the reference page doesn't list %q", rt.Summary)
+		}
+	}
+}
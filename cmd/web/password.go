@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/forms"
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Password-protected snippets. Reading one takes its password, entered once
+// on the unlock form, which remembers the snippet in the session, or sent
+// with every request in the X-Snippet-Password header, which is how API
+// clients and scripts fetching /raw do it. The author and administrators
+// never need it. Every attempt counts against Config.UnlockLimit for that
+// snippet, whoever makes it, so a password can't be guessed by spreading the
+// attempts over many clients; right ones are refunded.
+
+const (
+	snippetPasswordHeader = "X-Snippet-Password"
+	unlockedSessionKey    = "unlockedSnippets"
+)
+
+// maxSnippetPasswordBytes is the longest password bcrypt can hash.
+const maxSnippetPasswordBytes = 72
+
+// maxUnlockedSnippets caps how many unlocked snippets a session remembers;
+// the oldest are forgotten first.
+const maxUnlockedSnippets = 100
+
+// errPasswordRequired means the request came without a password for a
+// protected snippet.
+var errPasswordRequired = errors.New("snippet password required")
+
+// tooManyAttempts is returned by checkSnippetPassword once the snippet's
+// unlock limit is used up.
+type tooManyAttempts struct {
+	retryAfter time.Duration
+}
+
+func (e *tooManyAttempts) Error() string {
+	return "too many password attempts"
+}
+
+// validateSnippetPassword checks the optional password field of the create
+// form.
+func validateSnippetPassword(form *forms.Form) {
+	if len(form.Get("password")) > maxSnippetPasswordBytes {
+		form.Errors.Add("password", fmt.Sprintf("This field is too long (maximum is %d bytes)", maxSnippetPasswordBytes))
+	}
+}
+
+// checkSnippetPassword compares password with s's, counting the attempt
+// against s's unlock limit. It returns nil, models.ErrInvalidCredentials or
+// a *tooManyAttempts.
+func (app *application) checkSnippetPassword(s *models.Snippet, password string) error {
+	key := "snippet:" + strconv.Itoa(s.ID)
+
+	if app.unlockLimiter != nil {
+		if res := app.unlockLimiter.Allow(key); !res.Allowed {
+			return &tooManyAttempts{res.RetryAfter}
+		}
+	}
+
+	err := s.CheckPassword(password)
+	if err == nil && app.unlockLimiter != nil {
+		app.unlockLimiter.Refund(key)
+	}
+	return err
+}
+
+// passwordAccess returns nil if r may read s without help from a session: s
+// has no password, the user may change s, or the X-Snippet-Password header
+// holds the password. Otherwise it returns errPasswordRequired or the error
+// from checkSnippetPassword.
+func (app *application) passwordAccess(r *http.Request, s *models.Snippet) error {
+	if !s.Protected() || app.canModify(r, s) {
+		return nil
+	}
+
+	password := r.Header.Get(snippetPasswordHeader)
+	if password == "" {
+		return errPasswordRequired
+	}
+	return app.checkSnippetPassword(s, password)
+}
+
+// snippetAccess is passwordAccess for routes with a session, where having
+// unlocked s earlier counts too.
+func (app *application) snippetAccess(r *http.Request, s *models.Snippet) error {
+	if s.Protected() {
+		for _, id := range app.unlockedSnippets(r) {
+			if id == s.ID {
+				return nil
+			}
+		}
+	}
+	return app.passwordAccess(r, s)
+}
+
+// unlockedSnippets returns the IDs of the snippets unlocked in the session,
+// oldest first.
+func (app *application) unlockedSnippets(r *http.Request) []int {
+	ids, _ := app.session.Get(r.Context(), unlockedSessionKey).([]int)
+	return ids
+}
+
+// lockedAs answers a request for s that snippetAccess or passwordAccess
+// refused with err: HTML gets the unlock form, other formats a 403, or a 429
+// once the snippet's unlock limit is used up.
+func (app *application) lockedAs(w http.ResponseWriter, r *http.Request, format string, s *models.Snippet, err error) {
+	if format == formatHTML {
+		app.renderUnlock(w, r, s, nil, err)
+		return
+	}
+
+	var tooMany *tooManyAttempts
+	var status int
+	var code, detail string
+
+	switch {
+	case errors.As(err, &tooMany):
+		retry := seconds(tooMany.retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		status, code = http.StatusTooManyRequests, codeRateLimited
+		detail = fmt.Sprintf("Too many wrong passwords for this snippet. Try again in %d seconds.", retry)
+	case errors.Is(err, models.ErrInvalidCredentials):
+		status, code = http.StatusForbidden, codeInvalidPassword
+		detail = "The snippet password is wrong."
+	case errors.Is(err, errPasswordRequired):
+		status, code = http.StatusForbidden, codePasswordRequired
+		detail = "This snippet is password-protected. Send the password in the " + snippetPasswordHeader + " header."
+	default:
+		app.serverErrorAs(w, r, format, err)
+		return
+	}
+
+	if format == formatJSON {
+		app.apiProblem(w, r, status, code, detail)
+	} else {
+		http.Error(w, detail, status)
+	}
+}
+
+// renderUnlock shows the unlock form for s, with the outcome of the last
+// attempt, err, on it.
+func (app *application) renderUnlock(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form, err error) {
+	if form == nil {
+		form = forms.New(nil)
+	}
+	// Never send the password back.
+	form.Del("password")
+
+	var tooMany *tooManyAttempts
+	status := http.StatusForbidden
+
+	switch {
+	case errors.As(err, &tooMany):
+		retry := seconds(tooMany.retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		status = http.StatusTooManyRequests
+		form.Errors.Add("password", fmt.Sprintf("Too many wrong passwords. Try again in %d seconds.", retry))
+	case errors.Is(err, models.ErrInvalidCredentials):
+		form.Errors.Add("password", "Wrong password")
+	case errors.Is(err, errPasswordRequired):
+	default:
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderStatus(w, r, status, "unlock.page.html", &templateData{
+		Form:    form,
+		Snippet: s,
+	})
+}
+
+// unlockSnippet checks the password posted from the unlock form and, if it
+// is right, remembers in the session that the snippet is unlocked.
+func (app *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippetFromURL(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if app.snippetAccess(r, s) == nil {
+		http.Redirect(w, r, "/snippet/"+s.Ref(), http.StatusSeeOther)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("password")
+	if !form.Valid() {
+		app.renderUnlock(w, r, s, form, errPasswordRequired)
+		return
+	}
+
+	if err := app.checkSnippetPassword(s, form.Get("password")); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.infoLog.Printf("request %s: wrong password for snippet %d", requestID(r), s.ID)
+		}
+		app.renderUnlock(w, r, s, form, err)
+		return
+	}
+
+	ids := append(app.unlockedSnippets(r), s.ID)
+	if len(ids) > maxUnlockedSnippets {
+		ids = ids[len(ids)-maxUnlockedSnippets:]
+	}
+	app.session.Put(r.Context(), unlockedSessionKey, ids)
+
+	http.Redirect(w, r, "/snippet/"+s.Ref(), http.StatusSeeOther)
+}
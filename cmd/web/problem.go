@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gbih/snippetbox/pkg/forms"
+)
+
+// API errors are reported as RFC 7807 problem details. Besides the standard
+// members every problem carries a machine-readable code, which is also the
+// last part of its type URI, and the ID of the request that caused it.
+type problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"request_id"`
+	Errors    map[string][]string `json:"errors,omitempty"` // field name -> messages
+	Lines     []*lineErrors       `json:"lines,omitempty"`  // for NDJSON request bodies
+}
+
+// lineErrors reports what is wrong with one line of an NDJSON request body.
+type lineErrors struct {
+	Line   int                 `json:"line"`
+	Errors map[string][]string `json:"errors"` // field name -> messages
+}
+
+// Problem codes. Clients should switch on these rather than on the detail
+// text, which is meant for people and may change.
+const (
+	codeBadRequest         = "bad_request"
+	codeBodyTooLarge       = "body_too_large"
+	codeInvalidJSON        = "invalid_json"
+	codeInvalidCursor      = "invalid_cursor"
+	codeValidationFailed   = "validation_failed"
+	codeUnauthorized       = "unauthorized"
+	codeInvalidToken       = "invalid_token"
+	codeForbidden          = "forbidden"
+	codeInsufficientScope  = "insufficient_scope"
+	codeNotFound           = "not_found"
+	codeMethodNotAllowed   = "method_not_allowed"
+	codePreconditionFailed = "precondition_failed"
+	codePasswordRequired   = "password_required"
+	codeInvalidPassword    = "invalid_password"
+	codeRateLimited        = "rate_limited"
+	codeInternalError      = "internal_error"
+)
+
+// problemTypePrefix turns a code into the problem's type URI.
+const problemTypePrefix = "urn:snippetbox:problem:"
+
+// apiProblem writes an application/problem+json response.
+func (app *application) apiProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	app.writeProblem(w, &problem{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID(r),
+	})
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, p *problem) {
+	js, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(p.Status)
+	w.Write(js)
+}
+
+// apiNotFound is the API counterpart of notFound.
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiProblem(w, r, http.StatusNotFound, codeNotFound, "")
+}
+
+// apiServerError is the API counterpart of serverError. The stack trace is
+// logged with the request ID, which the client gets in place of the details.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	trace := fmt.Sprintf("request %s: %s\n%s", requestID(r), err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	app.apiProblem(w, r, http.StatusInternalServerError, codeInternalError, "")
+}
+
+// apiUnauthorized answers 401 with a challenge telling the client to send a
+// bearer token.
+func (app *application) apiUnauthorized(w http.ResponseWriter, r *http.Request, code, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+	app.apiProblem(w, r, http.StatusUnauthorized, code, detail)
+}
+
+// apiValidationError reports the errors collected on form with a 422, keyed
+// by JSON field name.
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	fields := map[string][]string{}
+	for field, messages := range form.Errors {
+		if name, ok := apiFieldNames[field]; ok {
+			field = name
+		}
+		fields[field] = messages
+	}
+
+	app.writeProblem(w, &problem{
+		Type:      problemTypePrefix + codeValidationFailed,
+		Title:     http.StatusText(http.StatusUnprocessableEntity),
+		Status:    http.StatusUnprocessableEntity,
+		Detail:    "One or more fields are invalid.",
+		Instance:  r.URL.Path,
+		Code:      codeValidationFailed,
+		RequestID: requestID(r),
+		Errors:    fields,
+	})
+}
+
+//----------
+
+// requestIDRX limits which client-supplied request IDs are passed through,
+// so they are safe to echo in headers, bodies and logs.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// setRequestID gives every request an ID, taken from a well-formed incoming
+// X-Request-ID header (e.g. set by a proxy) or generated, and echoes it in
+// the response.
+func (app *application) setRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 12)
+			if _, err := rand.Read(b); err != nil {
+				app.serverError(w, err)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		r.Header.Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID returns the ID assigned by setRequestID.
+func requestID(r *http.Request) string {
+	return r.Header.Get("X-Request-ID")
+}
+
+// apiProblems makes sure errors under /api/ are always problem+json, even
+// those written by code that knows nothing about the API: the router's own
+// 404 and 405 responses, and recoverPanic. It rewrites any text/plain error
+// response on its way out.
+func (app *application) apiProblems(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&problemWriter{ResponseWriter: w, app: app, r: r}, r)
+	})
+}
+
+// problemWriter is the http.ResponseWriter used by apiProblems.
+type problemWriter struct {
+	http.ResponseWriter
+	app       *application
+	r         *http.Request
+	rewritten bool
+}
+
+var problemCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusTooManyRequests:     codeRateLimited,
+	http.StatusInternalServerError: codeInternalError,
+}
+
+func (pw *problemWriter) WriteHeader(status int) {
+	h := pw.Header()
+	if status >= 400 && strings.HasPrefix(h.Get("Content-Type"), "text/plain") {
+		code, ok := problemCodes[status]
+		if !ok {
+			code = codeBadRequest
+			if status >= 500 {
+				code = codeInternalError
+			}
+		}
+
+		h.Del("Content-Type")
+		h.Del("X-Content-Type-Options")
+		pw.rewritten = true
+		pw.app.apiProblem(pw.ResponseWriter, pw.r, status, code, "")
+		return
+	}
+
+	pw.ResponseWriter.WriteHeader(status)
+}
+
+func (pw *problemWriter) Write(b []byte) (int, error) {
+	// The plain-text body has already been replaced.
+	if pw.rewritten {
+		return len(b), nil
+	}
+	return pw.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (pw *problemWriter) Flush() {
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
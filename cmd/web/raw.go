@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Plain views of a snippet's content for scripts, e.g.
+//
+//	curl -s localhost:4000/snippet/1/raw | sh
+//	curl -sOJ localhost:4000/snippet/1/download
+//
+// Both are served with http.ServeContent, which adds Range and If-Range
+// support on top of the usual conditional requests. Password-protected
+// snippets need the password in the X-Snippet-Password header, unless the
+// browser's session has unlocked them.
+
+// rawSnippet serves the content exactly as stored.
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	app.serveContent(w, r, "raw", false)
+}
+
+// downloadSnippet serves the content as an attachment named after the title.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	app.serveContent(w, r, "download", true)
+}
+
+func (app *application) serveContent(w http.ResponseWriter, r *http.Request, variant string, attachment bool) {
+	s, err := app.snippetFromURL(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Browsers have the session; scripts send the password.
+	if err := app.snippetAccess(r, s); err != nil {
+		app.lockedAs(w, r, formatText, s, err)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	if attachment {
+		h.Set("Content-Disposition", contentDisposition(snippetFilename(s)))
+	}
+	setValidators(w, s, snippetETag(s, variant), false)
+
+	http.ServeContent(w, r, "", s.Updated, strings.NewReader(s.Content))
+}
+
+// languageExtensions maps tags naming a language to its file extension.
+var languageExtensions = map[string]string{
+	"bash":       "sh",
+	"c":          "c",
+	"c#":         "cs",
+	"c++":        "cpp",
+	"cpp":        "cpp",
+	"css":        "css",
+	"csharp":     "cs",
+	"go":         "go",
+	"golang":     "go",
+	"haskell":    "hs",
+	"html":       "html",
+	"java":       "java",
+	"javascript": "js",
+	"js":         "js",
+	"json":       "json",
+	"kotlin":     "kt",
+	"lua":        "lua",
+	"markdown":   "md",
+	"perl":       "pl",
+	"php":        "php",
+	"python":     "py",
+	"ruby":       "rb",
+	"rust":       "rs",
+	"shell":      "sh",
+	"sql":        "sql",
+	"swift":      "swift",
+	"toml":       "toml",
+	"typescript": "ts",
+	"xml":        "xml",
+	"yaml":       "yaml",
+}
+
+// maxFilenameLength caps the length of a download's name, before the
+// extension.
+const maxFilenameLength = 60
+
+// snippetFilename derives a file name from the snippet's title, with the
+// extension of the first tag naming a language, or .txt.
+func snippetFilename(s *models.Snippet) string {
+	ext := "txt"
+	for _, tag := range s.Tags {
+		if e, ok := languageExtensions[tag]; ok {
+			ext = e
+			break
+		}
+	}
+
+	// Keep letters and digits of any script, collapse everything else into
+	// single dashes.
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(s.Title) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxFilenameLength {
+			break
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+	return name + "." + ext
+}
+
+// contentDisposition builds an attachment header for name, with an ASCII
+// fallback for old clients and the full UTF-8 name in filename* (RFC 6266).
+func contentDisposition(name string) string {
+	var ascii, encoded strings.Builder
+	for _, c := range name {
+		if c < 0x80 && c != '"' && c != '\\' && unicode.IsPrint(c) {
+			ascii.WriteRune(c)
+		} else {
+			ascii.WriteByte('_')
+		}
+	}
+	for _, c := range []byte(name) {
+		if isAttrChar(c) {
+			encoded.WriteByte(c)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+
+	if ascii.String() == name {
+		return fmt.Sprintf(`attachment; filename="%s"`, name)
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii.String(), encoded.String())
+}
+
+// isAttrChar reports whether c may appear unescaped in an RFC 5987 value.
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		tags  []string
+		want  string
+	}{
+		{"Plain", "Hello World", nil, "hello-world.txt"},
+		{"Language tag", "Hello World", []string{"db", "go", "python"}, "hello-world.go"},
+		{"Punctuation collapsed", `  "Quoted" -- title!? `, nil, "quoted-title.txt"},
+		{"Non-ASCII kept", "Über Grüße 日本語", []string{"c++"}, "über-grüße-日本語.cpp"},
+		{"Nothing usable", `"!?"`, nil, "snippet-7.txt"},
+		{"Long title", strings.Repeat("word ", 30), nil, strings.Repeat("word-", 12) + "w.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &models.Snippet{ID: 7, Title: tt.title, Tags: tt.tags}
+			if got := snippetFilename(s); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"ASCII", "hello-world.go", `attachment; filename="hello-world.go"`},
+		{"Non-ASCII", "über-日本.txt", `attachment; filename="_ber-__.txt"; filename*=UTF-8''%C3%BCber-%E6%97%A5%E6%9C%AC.txt`},
+		{"Quote", `say-"hi".txt`, `attachment; filename="say-_hi_.txt"; filename*=UTF-8''say-%22hi%22.txt`},
+		{"Backslash and space", `a\b c.txt`, `attachment; filename="a_b c.txt"; filename*=UTF-8''a%5Cb%20c.txt`},
+		{"Control character", "a\nb.txt", `attachment; filename="a_b.txt"; filename*=UTF-8''a%0Ab.txt`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentDisposition(tt.filename); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	const content = "#!/bin/sh\necho <hello>\n"
+	if _, err := app.snippets.Insert(0, "Grüße script", content, "7", models.VisibilityPublic, "", []string{"shell"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastModified := s.Updated.UTC().Format(http.TimeFormat)
+
+	_, header, _ := ts.get(t, "/snippet/1/raw")
+	etag := header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantCode   int
+		wantBody   string
+		wantHeader map[string]string
+	}{
+		{
+			name: "Raw", path: "/snippet/1/raw",
+			wantCode: http.StatusOK, wantBody: content,
+			wantHeader: map[string]string{
+				"Content-Type":        "text/plain; charset=utf-8",
+				"Content-Disposition": "",
+				"Accept-Ranges":       "bytes",
+				"Last-Modified":       lastModified,
+			},
+		},
+		{
+			name: "Download", path: "/snippet/1/download",
+			wantCode: http.StatusOK, wantBody: content,
+			wantHeader: map[string]string{
+				"Content-Type":        "text/plain; charset=utf-8",
+				"Content-Disposition": `attachment; filename="gr__e-script.sh"; filename*=UTF-8''gr%C3%BC%C3%9Fe-script.sh`,
+			},
+		},
+		{
+			name: "Range", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=10-13"}},
+			wantCode: http.StatusPartialContent, wantBody: "echo",
+			wantHeader: map[string]string{"Content-Range": "bytes 10-13/23"},
+		},
+		{
+			name: "Suffix range", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=-8"}},
+			wantCode: http.StatusPartialContent, wantBody: "<hello>\n",
+		},
+		{
+			name: "Unsatisfiable range", path: "/snippet/1/raw",
+			header:     http.Header{"Range": {"bytes=100-"}},
+			wantCode:   http.StatusRequestedRangeNotSatisfiable,
+			wantHeader: map[string]string{"Content-Range": "bytes */23"},
+		},
+		{
+			name: "If-Range with the ETag", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=0-8"}, "If-Range": {etag}},
+			wantCode: http.StatusPartialContent, wantBody: "#!/bin/sh",
+		},
+		{
+			name: "If-Range with an old ETag", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=0-8"}, "If-Range": {`"old"`}},
+			wantCode: http.StatusOK, wantBody: content,
+		},
+		{
+			name: "If-Range with the date", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=0-8"}, "If-Range": {lastModified}},
+			wantCode: http.StatusPartialContent, wantBody: "#!/bin/sh",
+		},
+		{
+			name: "If-Range with an old date", path: "/snippet/1/raw",
+			header:   http.Header{"Range": {"bytes=0-8"}, "If-Range": {s.Updated.Add(-time.Hour).UTC().Format(http.TimeFormat)}},
+			wantCode: http.StatusOK, wantBody: content,
+		},
+		{
+			name: "Download variant has its own ETag", path: "/snippet/1/download",
+			header:   http.Header{"Range": {"bytes=0-8"}, "If-Range": {etag}},
+			wantCode: http.StatusOK, wantBody: content,
+		},
+		{
+			name: "Not modified", path: "/snippet/1/raw",
+			header:   http.Header{"If-None-Match": {etag}},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name: "Missing", path: "/snippet/2/raw",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodGet, tt.path, tt.header, "")
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t.Errorf("got body %q; want %q", body, tt.wantBody)
+			}
+			for name, want := range tt.wantHeader {
+				if got := header.Get(name); got != want {
+					t.Errorf("got %s %q; want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
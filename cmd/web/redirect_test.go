@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		method    string
+		host      string
+		target    string
+		wantCode  int
+		wantURL   string
+	}{
+		{"Default port", ":443", http.MethodGet, "example.com", "/snippet/1?x=y", http.StatusMovedPermanently, "https://example.com/snippet/1?x=y"},
+		{"Default port from a host with a port", ":443", http.MethodGet, "example.com:80", "/", http.StatusMovedPermanently, "https://example.com/"},
+		{"Other port", ":4000", http.MethodGet, "example.com:8080", "/", http.StatusMovedPermanently, "https://example.com:4000/"},
+		{"Address with a host", "localhost:4000", http.MethodHead, "localhost:4001", "/", http.StatusMovedPermanently, "https://localhost:4000/"},
+		{"IPv6", ":443", http.MethodGet, "[::1]:80", "/", http.StatusMovedPermanently, "https://[::1]/"},
+		{"IPv6 with a port", ":4000", http.MethodGet, "[::1]", "/", http.StatusMovedPermanently, "https://[::1]:4000/"},
+		{"POST", ":443", http.MethodPost, "example.com", "/snippet/create", http.StatusPermanentRedirect, "https://example.com/snippet/create"},
+		{"No host", ":443", http.MethodGet, "", "/", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Host = tt.host
+
+			httpsRedirect(tt.httpsAddr).ServeHTTP(rr, r)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("got status %d; want %d", rr.Code, tt.wantCode)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wantURL {
+				t.Errorf("got Location %q; want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/bmizerany/pat"
 	"github.com/gbih/snippetbox/pkg/alice"
+	"github.com/gbih/snippetbox/pkg/models"
 )
 
 func (app *application) routes() http.Handler {
@@ -12,21 +13,54 @@ func (app *application) routes() http.Handler {
 	// Use alice to create our "standard" middleware chain,
 	// used for every request our app receives. This is just our
 	// middleware arranged in a list or slice data structure.
-	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	standardMiddleware := alice.New(app.setRequestID, app.apiProblems, app.recoverPanic, app.logRequest, app.securityHeaders)
 
 	// Create a new middleware chain containing the middleware specific to
-	// our dynamic application routes. For now, this chain will only contain
-	// the session middleware but we'll add more to it later.
-	dynamicMiddleware := alice.New(app.session.LoadAndSave)
+	// our dynamic application routes: the session middleware, followed by
+	// authenticate and csrf, which need the session to have been loaded.
+	dynamicMiddleware := alice.New(app.session.LoadAndSave, app.authenticate, app.csrf)
+
+	// API clients authenticate with personal tokens instead of the session
+	// cookie; routes that change data also need a token with the scope
+	// named in the route table. Every API request counts against APILimit,
+	// and those that change data against APIWriteLimit as well.
+	apiMiddleware := alice.New(app.cors, app.authenticateToken, app.rateLimit(app.config.APILimit))
+	apiWriteLimit := app.rateLimit(app.config.APIWriteLimit)
 
 	mux := pat.New()
 
 	// Update these routes to use the new dynamic middleware chain followed
 	// by the appropriate handler function.
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
-	mux.Get("/snippet/create", dynamicMiddleware.ThenFunc(app.createSnippetForm))
-	mux.Post("/snippet/create", dynamicMiddleware.ThenFunc(app.createSnippet))
+	mux.Get("/snippets", dynamicMiddleware.ThenFunc(app.listSnippets))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.searchSnippets))
+	mux.Get("/tags", dynamicMiddleware.ThenFunc(app.listTags))
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.listSnippets))
+	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippetForm))
+	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication, app.rateLimit(app.config.CreateLimit)).ThenFunc(app.createSnippet))
 	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/snippet/:id/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Get("/snippet/:id/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteSnippet))
+	mux.Get("/snippet/:id/history", dynamicMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/snippet/:id/history/:rev", dynamicMiddleware.ThenFunc(app.showRevision))
+	mux.Post("/snippet/:id/history/:rev/restore", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.restoreRevision))
+
+	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
+	mux.Post("/user/signup", dynamicMiddleware.ThenFunc(app.signupUser))
+	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
+	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
+	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser))
+	mux.Get("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.listTokens))
+	mux.Post("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createToken))
+	mux.Post("/user/tokens/:id/revoke", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.revokeToken))
+
+	// Browsers post CSP violation reports without cookies. Anyone can send
+	// them, so they are rate limited to keep the log readable.
+	mux.Post("/csp-report", alice.New(app.rateLimit(app.config.CSPReportLimit)).ThenFunc(app.cspReport))
 
 	// mux := http.NewServeMux()
 	// mux.HandleFunc("/", app.home)
@@ -38,10 +72,40 @@ func (app *application) routes() http.Handler {
 	fileServer := http.FileServer(assetsFileSystem{http.Dir("./ui/static/")})
 	mux.Get("/static/", http.StripPrefix("/static", fileServer))
 
-	// APIs
-	mux.Get("/api/v1/snippet", http.HandlerFunc(app.apiShowSnippet))
-	mux.Get("/api/v1/test", http.HandlerFunc(app.apiTest))
-	mux.Get("/api/v1/home", http.HandlerFunc(app.apiHome))
+	// APIs. The same table generates /api/v1/openapi.json, so every route
+	// registered here is documented there.
+	var patterns []string
+	methods := map[string][]string{}
+
+	for _, rt := range app.apiRoutes() {
+		if methods[rt.Pattern] == nil {
+			patterns = append(patterns, rt.Pattern)
+		}
+		methods[rt.Pattern] = append(methods[rt.Pattern], rt.Method)
+
+		chain := apiMiddleware
+		if rt.Scope != "" {
+			chain = apiMiddleware.Append(app.requireScope(rt.Scope))
+		}
+		if rt.Scope == models.ScopeWrite {
+			chain = chain.Append(apiWriteLimit)
+		}
+		if rt.Admin {
+			chain = chain.Append(app.requireAdmin)
+		}
+		if rt.Method == http.MethodGet {
+			mux.Get(rt.Pattern, chain.ThenFunc(rt.Handler)) // also answers HEAD
+		} else {
+			mux.Add(rt.Method, rt.Pattern, chain.ThenFunc(rt.Handler))
+		}
+	}
+
+	// CORS preflight requests carry no credentials, so OPTIONS skips the
+	// rest of the API chain.
+	for _, pattern := range patterns {
+		mux.Add(http.MethodOptions, pattern, app.preflight(methods[pattern]))
+	}
+	mux.Get("/api/v1/docs", dynamicMiddleware.ThenFunc(app.apiReference))
 	// mux.HandleFunc("/api/v1/snippet", app.apiShowSnippet)
 	// mux.HandleFunc("/api/v1/test", app.apiTest)
 	// mux.HandleFunc("/api/v1/home", app.apiHome)
@@ -56,7 +120,7 @@ func (app *application) routes() http.Handler {
 
 	// return (app.recoverPanic(
 	// 	app.logRequest(
-	// 		secureHeaders(mux))))
+	// 		app.securityHeaders(mux))))
 
 	// Return the alice "standard" middleware chain, followed by servemux
 	return standardMiddleware.Then(mux)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Security headers sent with every response, configured by the CSP, HSTS
+// and policy fields of Config.
+
+// cspNonceToken is replaced in Config.CSP with the request's nonce.
+const cspNonceToken = "{nonce}"
+
+// cspReportGroup names the Reporting API endpoint CSP reports go to.
+const cspReportGroup = "csp"
+
+// maxCSPReportBytes caps the size of a CSP violation report.
+const maxCSPReportBytes = 64 << 10
+
+// securityHeaders sets the security headers. When the policy uses nonces
+// each request gets a fresh one, kept in the request context for
+// addDefaultData.
+func (app *application) securityHeaders(next http.Handler) http.Handler {
+	cfg := app.config
+
+	csp := cfg.CSP
+	if csp != "" && cfg.CSPReportURI != "" {
+		csp += "; report-uri " + cfg.CSPReportURI + "; report-to " + cspReportGroup
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(csp, cspNonceToken)
+
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int(cfg.HSTSMaxAge.Seconds()))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+
+		if csp != "" {
+			policy := csp
+			if useNonce {
+				b := make([]byte, 16)
+				if _, err := rand.Read(b); err != nil {
+					app.serverError(w, err)
+					return
+				}
+				nonce := base64.StdEncoding.EncodeToString(b)
+				policy = strings.ReplaceAll(policy, cspNonceToken, nonce)
+				r = r.WithContext(context.WithValue(r.Context(), contextKeyNonce, nonce))
+			}
+			h.Set(cspHeader, policy)
+			if cfg.CSPReportURI != "" {
+				h.Set("Reporting-Endpoints", fmt.Sprintf("%s=%q", cspReportGroup, cfg.CSPReportURI))
+			}
+		}
+
+		// Browsers ignore HSTS over plain HTTP anyway.
+		if hsts != "" && r.TLS != nil {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.PermissionsPolicy != "" {
+			h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+		}
+		if cfg.COOP != "" {
+			h.Set("Cross-Origin-Opener-Policy", cfg.COOP)
+		}
+
+		// For browsers that predate CSP's frame-ancestors.
+		h.Set("X-Frame-Options", "deny")
+		h.Set("X-Content-Type-Options", "nosniff")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cspNonce returns the request's CSP nonce, or "" if the policy doesn't use
+// one.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(contextKeyNonce).(string)
+	return nonce
+}
+
+// cspViolation is the part of a violation report worth logging. Browsers
+// send either the original report-uri format, with these fields under a
+// "csp-report" key, or the Reporting API format, a list of reports with the
+// same fields, in camel case, under "body".
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	DocumentURL        string `json:"documentURL"`
+	BlockedURI         string `json:"blocked-uri"`
+	BlockedURL         string `json:"blockedURL"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	SourceFile         string `json:"source-file"`
+	SourceFileCamel    string `json:"sourceFile"`
+	LineNumber         int    `json:"line-number"`
+	LineNumberCamel    int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+// cspReport collects the violation reports browsers send to CSPReportURI
+// and logs them. It always answers 204, since browsers don't look at the
+// response.
+func (app *application) cspReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportBytes))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var violations []*cspViolation
+
+	var legacy struct {
+		Report *cspViolation `json:"csp-report"`
+	}
+	var reports []struct {
+		Type string        `json:"type"`
+		Body *cspViolation `json:"body"`
+	}
+	if json.Unmarshal(body, &legacy) == nil && legacy.Report != nil {
+		violations = append(violations, legacy.Report)
+	} else if json.Unmarshal(body, &reports) == nil {
+		for _, rep := range reports {
+			if rep.Type == "csp-violation" && rep.Body != nil {
+				violations = append(violations, rep.Body)
+			}
+		}
+	} else {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, v := range violations {
+		app.infoLog.Printf("CSP violation: %q blocked %q on %q (%s:%d) %s",
+			orDefault(v.ViolatedDirective, v.EffectiveDirective),
+			orDefault(v.BlockedURI, v.BlockedURL),
+			orDefault(v.DocumentURI, v.DocumentURL),
+			orDefault(v.SourceFile, v.SourceFileCamel),
+			v.LineNumber+v.LineNumberCamel,
+			v.Disposition)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"html"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/ratelimit"
+)
+
+var nonceRX = regexp.MustCompile(`'nonce-([A-Za-z0-9+/=]+)'`)
+
+func TestSecurityHeadersNonce(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.CSP = defaultCSP
+	app.config.CSPReportURI = "/csp-report"
+	ts := newTestServer(t, app.routes())
+
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		_, header, body := ts.get(t, "/")
+
+		csp := header.Get("Content-Security-Policy")
+		match := nonceRX.FindStringSubmatch(csp)
+		if match == nil {
+			t.Fatalf("no nonce in the policy %q", csp)
+		}
+		nonce := match[1]
+		nonces = append(nonces, nonce)
+
+		if !strings.Contains(html.UnescapeString(body), "nonce='"+nonce+"'") {
+			t.Errorf("the page's script doesn't carry the nonce %q", nonce)
+		}
+		if !strings.HasSuffix(csp, "; report-uri /csp-report; report-to csp") {
+			t.Errorf("got policy %q; want it to end with the report endpoints", csp)
+		}
+		if got, want := header.Get("Reporting-Endpoints"), `csp="/csp-report"`; got != want {
+			t.Errorf("got Reporting-Endpoints %q; want %q", got, want)
+		}
+	}
+
+	if nonces[0] == nonces[1] {
+		t.Error("two requests got the same nonce")
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		tls    bool
+		want   map[string]string // "" means the header must be absent
+	}{
+		{
+			name:   "Enforced policy",
+			config: Config{CSP: "default-src 'self'"},
+			want: map[string]string{
+				"Content-Security-Policy":             "default-src 'self'",
+				"Content-Security-Policy-Report-Only": "",
+				"Reporting-Endpoints":                 "",
+				"X-Frame-Options":                     "deny",
+				"X-Content-Type-Options":              "nosniff",
+			},
+		},
+		{
+			name:   "Report only",
+			config: Config{CSP: "default-src 'self'", CSPReportOnly: true, CSPReportURI: "/csp-report"},
+			want: map[string]string{
+				"Content-Security-Policy":             "",
+				"Content-Security-Policy-Report-Only": "default-src 'self'; report-uri /csp-report; report-to csp",
+			},
+		},
+		{
+			name:   "No policy",
+			config: Config{CSPReportURI: "/csp-report"},
+			want: map[string]string{
+				"Content-Security-Policy": "",
+				"Reporting-Endpoints":     "",
+			},
+		},
+		{
+			name:   "HSTS over plain HTTP",
+			config: Config{HSTSMaxAge: 365 * 24 * time.Hour},
+			want:   map[string]string{"Strict-Transport-Security": ""},
+		},
+		{
+			name:   "HSTS over TLS",
+			config: Config{HSTSMaxAge: 365 * 24 * time.Hour},
+			tls:    true,
+			want:   map[string]string{"Strict-Transport-Security": "max-age=31536000"},
+		},
+		{
+			name:   "HSTS off",
+			config: Config{},
+			tls:    true,
+			want:   map[string]string{"Strict-Transport-Security": ""},
+		},
+		{
+			name:   "Other policies",
+			config: Config{ReferrerPolicy: "no-referrer", PermissionsPolicy: "camera=()", COOP: "same-origin"},
+			want: map[string]string{
+				"Referrer-Policy":            "no-referrer",
+				"Permissions-Policy":         "camera=()",
+				"Cross-Origin-Opener-Policy": "same-origin",
+				"Content-Security-Policy":    "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			cfg := tt.config
+			app.config = &cfg
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			})
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			app.securityHeaders(next).ServeHTTP(rr, r)
+
+			for name, want := range tt.want {
+				if got := rr.Header().Get(name); got != want {
+					t.Errorf("got %s %q; want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCSPReport(t *testing.T) {
+	const legacy = `{"csp-report": {
+		"document-uri": "https://snippetbox.example.com/",
+		"blocked-uri": "https://evil.example.com/x.js",
+		"violated-directive": "script-src",
+		"source-file": "https://snippetbox.example.com/",
+		"line-number": 12,
+		"disposition": "enforce"
+	}}`
+	const reportingAPI = `[
+		{"type": "deprecation", "body": {"id": "x"}},
+		{"type": "csp-violation", "body": {
+			"documentURL": "https://snippetbox.example.com/",
+			"blockedURL": "inline",
+			"effectiveDirective": "script-src-elem",
+			"sourceFile": "https://snippetbox.example.com/",
+			"lineNumber": 3,
+			"disposition": "report"
+		}}
+	]`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantLog  string
+	}{
+		{"report-uri format", legacy, http.StatusNoContent,
+			`CSP violation: "script-src" blocked "https://evil.example.com/x.js" on "https://snippetbox.example.com/" (https://snippetbox.example.com/:12) enforce`},
+		{"Reporting API format", reportingAPI, http.StatusNoContent,
+			`CSP violation: "script-src-elem" blocked "inline" on "https://snippetbox.example.com/" (https://snippetbox.example.com/:3) report`},
+		{"No violations", `[{"type": "deprecation", "body": {}}]`, http.StatusNoContent, ""},
+		{"Not JSON", "blocked!", http.StatusBadRequest, ""},
+		{"Too large", `{"csp-report": {"blocked-uri": "` + strings.Repeat("x", maxCSPReportBytes) + `"}}`, http.StatusRequestEntityTooLarge, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			var logged bytes.Buffer
+			app.infoLog = log.New(&logged, "", 0)
+			ts := newTestServer(t, app.routes())
+
+			header := http.Header{"Content-Type": {"application/csp-report"}}
+			code, _, _ := ts.do(t, http.MethodPost, "/csp-report", header, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+
+			// logRequest writes to the same log.
+			var got string
+			for _, line := range strings.Split(logged.String(), "\n") {
+				if strings.HasPrefix(line, "CSP violation") {
+					got += line
+				}
+			}
+			if got != tt.wantLog {
+				t.Errorf("logged %q; want %q", got, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestCSPReportLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.CSPReportLimit = ratelimit.Limit{Burst: 2, Period: time.Minute}
+	ts := newTestServer(t, app.routes())
+
+	for i, want := range []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests} {
+		code, header, _ := ts.do(t, http.MethodPost, "/csp-report", nil, `{"csp-report": {}}`)
+		if code != want {
+			t.Errorf("report %d: got status %d; want %d", i, code, want)
+		}
+		if header.Get("X-Request-ID") == "" {
+			t.Errorf("report %d: no X-Request-ID", i)
+		}
+	}
+}
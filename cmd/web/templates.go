@@ -3,6 +3,7 @@ package main
 import (
 	"html/template"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gbih/snippetbox/pkg/forms"
@@ -10,19 +11,58 @@ import (
 )
 
 type templateData struct {
-	CurrentYear int
-	Flash       string
-	Snippet     *models.Snippet
-	Snippets    []*models.Snippet
-	Form        *forms.Form
+	AuthenticatedUser *models.User
+	CanModify         bool
+	CurrentYear       int
+	CSPNonce          string
+	Flash             string
+	Snippet           *models.Snippet
+	Snippets          []*models.Snippet
+	Form              *forms.Form
+	Page              *models.SnippetPage
+	Revision          *models.Revision
+	Revisions         []*models.Revision
+	Authors           map[int]string
+	ListURL           string
+	Tag               string
+	TagCounts         []*models.TagCount
+	Search            *models.SearchPage
+	Tokens            []*models.Token
+	NewToken          string
+	API               *openAPIDoc
+	NextURL           string
+	PrevURL           string
 	// FormData    url.Values        // access url.Values type
 	// FormErrors  map[string]string // redisplay data upon errors
+
+	csrfToken func() (string, error)
+}
+
+// CSRFToken returns the session's CSRF token for the page's forms. It is
+// only called when a page with a form is rendered, so visitors who never see
+// one don't get a token stored in their session. An error stops the page
+// from rendering.
+func (td *templateData) CSRFToken() (string, error) {
+	if td.csrfToken == nil {
+		return "", nil
+	}
+	return td.csrfToken()
 }
 
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// highlight HTML-escapes a search headline and turns the match markers the
+// models put around each hit into <mark> elements.
+func highlight(s string) template.HTML {
+	s = template.HTMLEscapeString(s)
+	s = highlighter.Replace(s)
+	return template.HTML(s)
+}
+
+var highlighter = strings.NewReplacer(models.HighlightStart, "<mark>", models.HighlightStop, "</mark>")
+
 // FuncMap is the type of the map defining the mapping from names to functions.
 // There are 18 built-in template functions. To define a custom function:
 // 1. Create a template.FuncMap object containing the custom humanDate() function.
@@ -30,6 +70,7 @@ func humanDate(t time.Time) string {
 
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlight,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+
+	"github.com/gbih/snippetbox/pkg/models/memory"
+)
+
+// newTestApplication returns an application backed by the memory stores,
+// the way main sets one up for -db-driver memory, with its logs discarded.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache("../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Some handlers still use the package-level session manager.
+	session = scs.New()
+	session.Store = memstore.New()
+
+	return &application{
+		config:        &Config{},
+		errorLog:      log.New(io.Discard, "", 0),
+		infoLog:       log.New(io.Discard, "", 0),
+		session:       session,
+		snippets:      &memory.SnippetModel{},
+		templateCache: templateCache,
+		tokens:        &memory.TokenModel{},
+		users:         &memory.UserModel{},
+	}
+}
+
+// testServer is an httptest.Server whose client keeps cookies and doesn't
+// follow redirects.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// do sends a request to path with the given headers and returns the
+// response status, headers and body.
+func (ts *testServer) do(t *testing.T, method, path string, header http.Header, body string) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, string(b)
+}
+
+func (ts *testServer) get(t *testing.T, path string) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, nil, "")
+}
+
+func (ts *testServer) postForm(t *testing.T, path string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return ts.do(t, http.MethodPost, path, header, form.Encode())
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='([^']+)'>`)
+
+// extractCSRFToken returns the CSRF token in a page's forms.
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no CSRF token found in body")
+	}
+	return html.UnescapeString(matches[1])
+}
+
+// login signs up a user with the memory store and logs the test client in
+// through the login form.
+func (ts *testServer) login(t *testing.T, app *application) {
+	t.Helper()
+
+	if err := app.users.Insert("Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa55word")
+	form.Add(csrfField, extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: got status %d; want %d", code, http.StatusSeeOther)
+	}
+}
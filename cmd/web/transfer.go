@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/forms"
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Bulk export and import, for moving snippets between installations. Both
+// speak newline-delimited JSON, one snippet per line, in the format of
+// GET /api/v1/snippets/:id, so an export can be fed straight back in:
+//
+//	curl -s -H 'Authorization: Bearer sbx_...' localhost:4000/api/v1/export > snippets.ndjson
+//	curl -s -H 'Authorization: Bearer sbx_...' --data-binary @snippets.ndjson localhost:4000/api/v1/import
+
+const ndjsonType = "application/x-ndjson"
+
+// maxImportBytes caps the size of an import body. Each line is also limited
+// to maxAPIBodyBytes.
+const maxImportBytes = 32 << 20
+
+// maxImportDays bounds expires_days, keeping expiry dates in a range every
+// database can store.
+const maxImportDays = 3650
+
+// exportLine is one line of an export: the snippet as the API shows it, plus
+// the password hash of a password-protected one, so an import protects it
+// with the same password.
+type exportLine struct {
+	*snippetJSON
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// apiExportSnippets streams every unexpired snippet matching the same
+// filters as GET /api/v1/snippets, unlisted, private and password-protected
+// ones included. It pages through the listing rather than loading everything
+// at once, flushing after each page.
+func (app *application) apiExportSnippets(w http.ResponseWriter, r *http.Request) {
+	// An export always starts at the beginning and pages through to the
+	// end itself.
+	q := r.URL.Query()
+	q.Del("limit")
+	q.Del("cursor")
+
+	opts, form := listOptions(q)
+	if !form.Valid() {
+		app.apiValidationError(w, r, form)
+		return
+	}
+	opts.Limit = models.MaxPageSize
+	opts.Hidden = true
+
+	page, err := app.snippets.List(opts)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonType)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		for _, s := range page.Snippets {
+			if err := enc.Encode(&exportLine{newSnippetJSON(s), string(s.PasswordHash)}); err != nil {
+				return // the client has gone away
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if page.Next == "" {
+			return
+		}
+
+		opts.Cursor = page.Next
+		page, err = app.snippets.List(opts)
+		if err != nil {
+			// It's too late to change the status, so all that's left is
+			// to log it and end the stream early.
+			app.errorLog.Printf("request %s: export: %v", requestID(r), err)
+			return
+		}
+	}
+}
+
+// importLine is one line of an import. Fields an export includes that can't
+// be imported, such as id, slug and created, are ignored: imported snippets
+// get new IDs and slugs, belong to the importing user and count as created
+// now. The expiry is taken from expires, rounded up to whole days from now,
+// or from expires_days.
+type importLine struct {
+	Title        *string    `json:"title"`
+	Content      *string    `json:"content"`
+	Tags         []string   `json:"tags"`
+	Expires      *time.Time `json:"expires"`
+	ExpiresDays  *int       `json:"expires_days"`
+	Visibility   *string    `json:"visibility"`
+	PasswordHash *string    `json:"password_hash"` // bcrypt, as exported
+}
+
+// importReport is the response to a successful import.
+type importReport struct {
+	DryRun bool  `json:"dry_run"`
+	Count  int   `json:"count"` // snippets imported, or that would be
+	IDs    []int `json:"ids"`   // in input order; empty for a dry run
+}
+
+// apiImportSnippets creates a snippet for every line of the body. All lines
+// are checked first and nothing is imported unless all of them are valid;
+// the inserts then run in a single transaction. With ?dry_run=true it stops
+// after checking.
+func (app *application) apiImportSnippets(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			app.apiProblem(w, r, http.StatusBadRequest, codeBadRequest, "The dry_run parameter must be true or false.")
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxAPIBodyBytes)
+
+	userID := app.authenticatedUser(r).ID
+	snippets := []*models.NewSnippet{}
+	var failed []*lineErrors
+
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		s, errs := parseImportLine(line)
+		if errs != nil {
+			failed = append(failed, &lineErrors{Line: n, Errors: errs})
+			continue
+		}
+		s.UserID = userID
+		snippets = append(snippets, s)
+	}
+
+	if err := scanner.Err(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.apiProblem(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+				fmt.Sprintf("The body is larger than %d bytes.", maxBytesErr.Limit))
+			return
+		}
+
+		detail := "Invalid request body: " + err.Error()
+		if errors.Is(err, bufio.ErrTooLong) {
+			detail = fmt.Sprintf("Line %d is longer than %d bytes.", n+1, maxAPIBodyBytes)
+		}
+		app.apiProblem(w, r, http.StatusBadRequest, codeBadRequest, detail)
+		return
+	}
+
+	if len(failed) > 0 {
+		app.writeProblem(w, &problem{
+			Type:      problemTypePrefix + codeValidationFailed,
+			Title:     http.StatusText(http.StatusUnprocessableEntity),
+			Status:    http.StatusUnprocessableEntity,
+			Detail:    fmt.Sprintf("%d of %d lines are invalid; nothing was imported.", len(failed), len(failed)+len(snippets)),
+			Instance:  r.URL.Path,
+			Code:      codeValidationFailed,
+			RequestID: requestID(r),
+			Lines:     failed,
+		})
+		return
+	}
+
+	if len(snippets) == 0 {
+		app.apiProblem(w, r, http.StatusBadRequest, codeBadRequest, "The body contains no snippets.")
+		return
+	}
+
+	report := &importReport{DryRun: dryRun, Count: len(snippets), IDs: []int{}}
+
+	if !dryRun {
+		ids, err := app.snippets.InsertMany(snippets)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		report.IDs = ids
+		app.infoLog.Printf("request %s: user %d imported %d snippets", requestID(r), userID, len(ids))
+	}
+
+	app.writeJSON(w, http.StatusOK, report)
+}
+
+// parseImportLine decodes and validates one line of an import, using the
+// same rules as the create form. It returns the errors by field name.
+func parseImportLine(line []byte) (*models.NewSnippet, map[string][]string) {
+	var in importLine
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	err := dec.Decode(&in)
+	if err == nil && dec.More() {
+		err = errors.New("line must contain a single JSON object")
+	}
+	if err != nil {
+		return nil, map[string][]string{"json": {err.Error()}}
+	}
+
+	v := url.Values{}
+	if in.Title != nil {
+		v.Set("title", *in.Title)
+	}
+	if in.Content != nil {
+		v.Set("content", *in.Content)
+	}
+	v.Set("tags", strings.Join(in.Tags, ","))
+	if in.Visibility != nil {
+		v.Set("visibility", *in.Visibility)
+	}
+
+	form := forms.New(v)
+	form.Required("title", "content")
+	tags := validateSnippetFields(form)
+
+	days := 0
+	switch {
+	case in.ExpiresDays != nil:
+		days = *in.ExpiresDays
+		if days < 1 || days > maxImportDays {
+			form.Errors.Add("expires_days", fmt.Sprintf("This field must be a number between 1 and %d", maxImportDays))
+		}
+	case in.Expires != nil:
+		days = int(math.Ceil(time.Until(*in.Expires).Hours() / 24))
+		if days < 1 {
+			form.Errors.Add("expires", "This snippet has already expired")
+		} else if days > maxImportDays {
+			form.Errors.Add("expires", fmt.Sprintf("This field must be at most %d days from now", maxImportDays))
+		}
+	default:
+		form.Errors.Add("expires", "Either expires or expires_days is required")
+	}
+
+	var hash []byte
+	if in.PasswordHash != nil && *in.PasswordHash != "" {
+		hash = []byte(*in.PasswordHash)
+		if !models.ValidPasswordHash(hash) {
+			form.Errors.Add("password_hash", "This field must be a bcrypt hash")
+		}
+	}
+
+	if !form.Valid() {
+		return nil, form.Errors
+	}
+
+	return &models.NewSnippet{
+		Title:   form.Get("title"),
+		Content: form.Get("content"),
+		Expires: strconv.Itoa(days),
+		Tags:    tags,
+
+		Visibility:   form.Get("visibility"),
+		PasswordHash: hash,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gbih/snippetbox/pkg/models"
+	"github.com/gbih/snippetbox/pkg/models/memory"
+)
+
+// adminUsers is a memory.UserModel whose users are all administrators, which
+// the memory store has no other way of making.
+type adminUsers struct {
+	*memory.UserModel
+}
+
+func (m adminUsers) Get(id int) (*models.User, error) {
+	u, err := m.UserModel.Get(id)
+	if u != nil {
+		u.Admin = true
+	}
+	return u, err
+}
+
+func TestParseImportLine(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := func(d time.Duration) string {
+		return time.Now().Add(d).UTC().Format(time.RFC3339)
+	}
+	line := func(extra string) string {
+		return `{"title": "Title", "content": "Content", ` + extra + `}`
+	}
+
+	tests := []struct {
+		name      string
+		line      string
+		wantDays  string
+		wantField string // the field with an error, if any
+	}{
+		{"Expires in days", line(`"expires_days": 7`), "7", ""},
+		{"Expires date", line(`"expires": "` + expires(36*time.Hour) + `"`), "2", ""},
+		{"Expires date at the limit", line(`"expires": "` + expires(maxImportDays*24*time.Hour-time.Hour) + `"`), fmt.Sprint(maxImportDays), ""},
+		{"Expires date past the limit", line(`"expires": "` + expires((maxImportDays+1)*24*time.Hour) + `"`), "", "expires"},
+		{"Expires date far in the future", line(`"expires": "9999-12-31T00:00:00Z"`), "", "expires"},
+		{"Expired", line(`"expires": "` + expires(-time.Hour) + `"`), "", "expires"},
+		{"Expires days past the limit", line(fmt.Sprintf(`"expires_days": %d`, maxImportDays+1)), "", "expires_days"},
+		{"Expires days zero", line(`"expires_days": 0`), "", "expires_days"},
+		{"No expiry", line(`"tags": ["go"]`), "", "expires"},
+		{"Password hash", line(`"expires_days": 1, "password_hash": "` + string(hash) + `"`), "1", ""},
+		{"Truncated password hash", line(`"expires_days": 1, "password_hash": "` + string(hash[:30]) + `"`), "", "password_hash"},
+		{"Password hash with extra bytes", line(`"expires_days": 1, "password_hash": "` + string(hash) + `xyz"`), "", "password_hash"},
+		{"Not a password hash", line(`"expires_days": 1, "password_hash": "secret"`), "", "password_hash"},
+		{"Not JSON", `title: Title`, "", "json"},
+		{"Two objects", line(`"expires_days": 1`) + line(`"expires_days": 1`), "", "json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := parseImportLine([]byte(tt.line))
+			if tt.wantField != "" {
+				if _, ok := errs[tt.wantField]; !ok {
+					t.Fatalf("got errors %v; want one for %q", errs, tt.wantField)
+				}
+				return
+			}
+			if errs != nil {
+				t.Fatalf("got errors %v", errs)
+			}
+			if s.Expires != tt.wantDays {
+				t.Errorf("got expires %q; want %q", s.Expires, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestImportSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	app.users = adminUsers{&memory.UserModel{}}
+	ts := newTestServer(t, app.routes())
+	token := insertAPIUser(t, app, "Alice")
+
+	line := `{"title": "Title", "content": "Content", "expires_days": 7}` + "\n"
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		wantCode    int
+		wantProblem string
+	}{
+		{"Import", "/api/v1/import", line + "\n" + line, http.StatusOK, ""},
+		{"Dry run", "/api/v1/import?dry_run=true", line, http.StatusOK, ""},
+		{"Bad dry_run", "/api/v1/import?dry_run=maybe", line, http.StatusBadRequest, codeBadRequest},
+		{"Invalid line", "/api/v1/import", line + `{"title": ""}`, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"Line too long", "/api/v1/import", strings.Repeat("x", maxAPIBodyBytes+1), http.StatusBadRequest, codeBadRequest},
+		{"Body too large", "/api/v1/import", strings.Repeat("\n", maxImportBytes+1), http.StatusRequestEntityTooLarge, codeBodyTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodPost, tt.path, apiHeader(token), tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d: %s", code, tt.wantCode, body)
+			}
+			if tt.wantProblem != "" {
+				if got := problemCode(t, body); got != tt.wantProblem {
+					t.Errorf("got problem %q; want %q", got, tt.wantProblem)
+				}
+			}
+		})
+	}
+
+	page, err := app.snippets.List(models.ListOptions{Limit: 10, Hidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Snippets) != 2 {
+		t.Errorf("got %d snippets; want the 2 from the one real import", len(page.Snippets))
+	}
+}
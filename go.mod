@@ -1,10 +1,12 @@
 module github.com/gbih/snippetbox
 
-go 1.15
+go 1.19
 
 require (
 	github.com/alexedwards/scs/postgresstore v0.0.0-20200528164450-40c2a5f7eae8
 	github.com/alexedwards/scs/v2 v2.3.1
 	github.com/bmizerany/pat v0.0.0-20170815010413-6226ea591a40
 	github.com/lib/pq v1.7.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
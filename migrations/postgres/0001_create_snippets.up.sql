@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS snippets (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	expires TIMESTAMPTZ NOT NULL,
+	mv1 TEXT NOT NULL DEFAULT '---',
+	mv2 TEXT NOT NULL DEFAULT '---',
+	mv3 TEXT NOT NULL DEFAULT '---'
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	CONSTRAINT users_uc_email UNIQUE (email)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS admin;
+
+DROP INDEX IF EXISTS idx_snippets_user_id;
+ALTER TABLE snippets DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,9 @@
+-- Full-text search vector, kept up to date by SnippetModel.Insert and Update.
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS search TSVECTOR;
+
+UPDATE snippets SET search =
+	setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B');
+
+ALTER TABLE snippets ALTER COLUMN search SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_snippets_search ON snippets USING GIN (search);
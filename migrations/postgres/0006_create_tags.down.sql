@@ -0,0 +1,17 @@
+-- Only the original foo/bar/baz values survive the round trip; any other
+-- tags are lost.
+ALTER TABLE snippets
+	ADD COLUMN mv1 TEXT NOT NULL DEFAULT '---',
+	ADD COLUMN mv2 TEXT NOT NULL DEFAULT '---',
+	ADD COLUMN mv3 TEXT NOT NULL DEFAULT '---';
+
+UPDATE snippets s SET
+	mv1 = CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = 'foo') THEN 'foo' ELSE '---' END,
+	mv2 = CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = 'bar') THEN 'bar' ELSE '---' END,
+	mv3 = CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = 'baz') THEN 'baz' ELSE '---' END;
+
+DROP TABLE IF EXISTS snippet_tags;
+DROP TABLE IF EXISTS tags;
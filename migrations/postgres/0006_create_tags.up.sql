@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(32) NOT NULL,
+	CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS snippet_tags (
+	snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippet_tags_tag_id ON snippet_tags (tag_id);
+
+-- Carry the old fixed checkbox values (foo/bar/baz, with "---" meaning
+-- unchecked) over as tags, then drop the columns.
+INSERT INTO tags (name)
+SELECT DISTINCT lower(v) FROM (
+	SELECT mv1 AS v FROM snippets
+	UNION SELECT mv2 FROM snippets
+	UNION SELECT mv3 FROM snippets
+) mv
+WHERE v <> '---' AND v <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO snippet_tags (snippet_id, tag_id)
+SELECT DISTINCT s.id, t.id FROM snippets s
+JOIN tags t ON t.name IN (lower(s.mv1), lower(s.mv2), lower(s.mv3))
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE snippets DROP COLUMN mv1, DROP COLUMN mv2, DROP COLUMN mv3;
@@ -0,0 +1,2 @@
+-- Lets the reaper find the oldest expired snippets without a full scan.
+CREATE INDEX IF NOT EXISTS idx_snippets_expires ON snippets (expires);
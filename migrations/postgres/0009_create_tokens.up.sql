@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS tokens (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	scope VARCHAR(10) NOT NULL,
+	hash BYTEA NOT NULL,
+	created TIMESTAMPTZ NOT NULL,
+	last_used TIMESTAMPTZ,
+	CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens (user_id);
@@ -0,0 +1,11 @@
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS visibility VARCHAR(8) NOT NULL DEFAULT 'public'
+	CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+-- Every snippet gets a slug, though only unlisted and private ones are
+-- addressed by it. Existing snippets are all public, and a snippet gets a
+-- fresh slug from the application when it stops being public, so these only
+-- need to be unique.
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS slug VARCHAR(32);
+UPDATE snippets SET slug = substr(md5(random()::text || id::text), 1, 16) WHERE slug IS NULL;
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,2 @@
+-- bcrypt hash of the password needed to read the snippet; NULL for none.
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS password_hash CHAR(60);
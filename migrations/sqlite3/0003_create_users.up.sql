@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	created DATETIME NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT 1,
+	CONSTRAINT users_uc_email UNIQUE (email)
+);
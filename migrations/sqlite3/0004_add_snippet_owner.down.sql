@@ -0,0 +1,33 @@
+-- The bundled SQLite has no DROP COLUMN, so rebuild both tables without the
+-- new columns.
+DROP INDEX IF EXISTS idx_snippets_user_id;
+
+CREATE TABLE snippets_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	mv1 TEXT NOT NULL DEFAULT '---',
+	mv2 TEXT NOT NULL DEFAULT '---',
+	mv3 TEXT NOT NULL DEFAULT '---'
+);
+INSERT INTO snippets_old (id, title, content, created, expires, mv1, mv2, mv3)
+	SELECT id, title, content, created, expires, mv1, mv2, mv3 FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_old RENAME TO snippets;
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+
+CREATE TABLE users_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	hashed_password CHAR(60) NOT NULL,
+	created DATETIME NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT 1,
+	CONSTRAINT users_uc_email UNIQUE (email)
+);
+INSERT INTO users_old (id, name, email, hashed_password, created, active)
+	SELECT id, name, email, hashed_password, created, active FROM users;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
@@ -0,0 +1,6 @@
+-- Snippets created before ownership was tracked keep a NULL owner and can
+-- only be changed by an admin.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_snippets_user_id ON snippets (user_id);
+
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Intentionally empty: SQLite search is done with LIKE (see
+-- sqlite.SnippetModel.Search). Kept so version numbers match the postgres
+-- migrations.
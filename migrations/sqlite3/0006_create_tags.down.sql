@@ -0,0 +1,29 @@
+-- Only the original foo/bar/baz values survive the round trip; any other
+-- tags are lost.
+CREATE TABLE snippets_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	mv1 TEXT NOT NULL DEFAULT '---',
+	mv2 TEXT NOT NULL DEFAULT '---',
+	mv3 TEXT NOT NULL DEFAULT '---',
+	user_id INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+INSERT INTO snippets_old (id, title, content, created, expires, user_id, mv1, mv2, mv3)
+	SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id,
+		CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE st.snippet_id = s.id AND t.name = 'foo') THEN 'foo' ELSE '---' END,
+		CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE st.snippet_id = s.id AND t.name = 'bar') THEN 'bar' ELSE '---' END,
+		CASE WHEN EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+			WHERE st.snippet_id = s.id AND t.name = 'baz') THEN 'baz' ELSE '---' END
+	FROM snippets s;
+
+DROP TABLE snippet_tags;
+DROP TABLE tags;
+DROP TABLE snippets;
+ALTER TABLE snippets_old RENAME TO snippets;
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+CREATE INDEX IF NOT EXISTS idx_snippets_user_id ON snippets (user_id);
@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(32) NOT NULL,
+	CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+-- Carry the old fixed checkbox values (foo/bar/baz, with "---" meaning
+-- unchecked) over as tags.
+INSERT OR IGNORE INTO tags (name)
+SELECT DISTINCT lower(v) FROM (
+	SELECT mv1 AS v FROM snippets
+	UNION SELECT mv2 FROM snippets
+	UNION SELECT mv3 FROM snippets
+)
+WHERE v <> '---' AND v <> '';
+
+CREATE TEMP TABLE mv_tags AS
+SELECT DISTINCT s.id AS snippet_id, t.id AS tag_id FROM snippets s
+JOIN tags t ON t.name IN (lower(s.mv1), lower(s.mv2), lower(s.mv3));
+
+-- The bundled SQLite has no DROP COLUMN, so rebuild snippets without the
+-- mv columns. This has to happen before snippet_tags exists: dropping the
+-- old table would otherwise cascade-delete the new links.
+CREATE TABLE snippets_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	user_id INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+INSERT INTO snippets_new (id, title, content, created, expires, user_id)
+	SELECT id, title, content, created, expires, user_id FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+CREATE INDEX IF NOT EXISTS idx_snippets_user_id ON snippets (user_id);
+
+CREATE TABLE IF NOT EXISTS snippet_tags (
+	snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_snippet_tags_tag_id ON snippet_tags (tag_id);
+
+INSERT INTO snippet_tags (snippet_id, tag_id) SELECT snippet_id, tag_id FROM mv_tags;
+DROP TABLE mv_tags;
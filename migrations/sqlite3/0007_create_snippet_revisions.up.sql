@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS snippet_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+	number INTEGER NOT NULL,
+	user_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	CONSTRAINT snippet_revisions_uc_number UNIQUE (snippet_id, number)
+);
+
+-- Start every existing snippet's history with its current state, credited to
+-- its owner at its creation time.
+INSERT INTO snippet_revisions (snippet_id, number, user_id, title, content, created)
+SELECT id, 1, user_id, title, content, created FROM snippets;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	scope VARCHAR(10) NOT NULL,
+	hash BLOB NOT NULL,
+	created DATETIME NOT NULL,
+	last_used DATETIME,
+	CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id ON tokens (user_id);
@@ -0,0 +1,30 @@
+-- The bundled SQLite has no DROP COLUMN, so rebuild snippets without the new
+-- columns. Dropping the old table deletes its tag links and revisions when
+-- foreign keys are enforced, so they are set aside and put back afterwards.
+DROP INDEX IF EXISTS idx_snippets_slug;
+
+CREATE TEMP TABLE keep_snippet_tags AS SELECT * FROM snippet_tags;
+CREATE TEMP TABLE keep_snippet_revisions AS SELECT * FROM snippet_revisions;
+
+CREATE TABLE snippets_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	user_id INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+INSERT INTO snippets_old (id, title, content, created, expires, user_id)
+	SELECT id, title, content, created, expires, user_id FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_old RENAME TO snippets;
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+CREATE INDEX IF NOT EXISTS idx_snippets_user_id ON snippets (user_id);
+CREATE INDEX IF NOT EXISTS idx_snippets_expires ON snippets (expires);
+
+DELETE FROM snippet_tags;
+INSERT INTO snippet_tags SELECT * FROM keep_snippet_tags;
+DELETE FROM snippet_revisions;
+INSERT INTO snippet_revisions SELECT * FROM keep_snippet_revisions;
+DROP TABLE keep_snippet_tags;
+DROP TABLE keep_snippet_revisions;
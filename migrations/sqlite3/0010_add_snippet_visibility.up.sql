@@ -0,0 +1,10 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(8) NOT NULL DEFAULT 'public'
+	CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+-- Every snippet gets a slug, though only unlisted and private ones are
+-- addressed by it. Existing snippets are all public, and a snippet gets a
+-- fresh slug from the application when it stops being public, so these only
+-- need to be unique. SQLite can't add a NOT NULL column without a default.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32);
+UPDATE snippets SET slug = lower(hex(randomblob(8))) WHERE slug IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,32 @@
+-- The bundled SQLite has no DROP COLUMN, so rebuild snippets without the new
+-- column. Dropping the old table deletes its tag links and revisions when
+-- foreign keys are enforced, so they are set aside and put back afterwards.
+CREATE TEMP TABLE keep_snippet_tags AS SELECT * FROM snippet_tags;
+CREATE TEMP TABLE keep_snippet_revisions AS SELECT * FROM snippet_revisions;
+
+CREATE TABLE snippets_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title VARCHAR(100) NOT NULL,
+	content TEXT NOT NULL,
+	created DATETIME NOT NULL,
+	expires DATETIME NOT NULL,
+	user_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+	visibility VARCHAR(8) NOT NULL DEFAULT 'public'
+		CHECK (visibility IN ('public', 'unlisted', 'private')),
+	slug VARCHAR(32)
+);
+INSERT INTO snippets_old (id, title, content, created, expires, user_id, visibility, slug)
+	SELECT id, title, content, created, expires, user_id, visibility, slug FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_old RENAME TO snippets;
+CREATE INDEX IF NOT EXISTS idx_snippets_created ON snippets (created);
+CREATE INDEX IF NOT EXISTS idx_snippets_user_id ON snippets (user_id);
+CREATE INDEX IF NOT EXISTS idx_snippets_expires ON snippets (expires);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_snippets_slug ON snippets (slug);
+
+DELETE FROM snippet_tags;
+INSERT INTO snippet_tags SELECT * FROM keep_snippet_tags;
+DELETE FROM snippet_revisions;
+INSERT INTO snippet_revisions SELECT * FROM keep_snippet_revisions;
+DROP TABLE keep_snippet_tags;
+DROP TABLE keep_snippet_revisions;
@@ -0,0 +1,2 @@
+-- bcrypt hash of the password needed to read the snippet; NULL for none.
+ALTER TABLE snippets ADD COLUMN password_hash CHAR(60);
@@ -3,10 +3,19 @@ package forms
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
 	"unicode/utf8"
 )
 
+// Use the regexp.MustCompile() function to parse a pattern and compile a
+// regular expression for sanity checking the format of an email address.
+// This returns a *regexp.Regexp object, or panics in the event of an error.
+// Doing this once at runtime, and storing the compiled regular expression
+// object in a variable, is more performant than re-compiling the pattern with
+// every request.
+var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
 // Create a custom Form struct, which anonymously embeds a url.Values object
 // (to hold the form data) and an Errors field to hold any validation errors
 // for the form data.
@@ -49,6 +58,32 @@ func (f *Form) MaxLength(field string, d int) {
 	}
 }
 
+// Implement a MinLength method to check that a specific field in the form
+// contains a minimum number of characters. If the check fails then add the
+// appropriate message to the form errors.
+func (f *Form) MinLength(field string, d int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if utf8.RuneCountInString(value) < d {
+		f.Errors.Add(field, fmt.Sprintf("This field is too short (minimum is %d characters)", d))
+	}
+}
+
+// Implement a MatchesPattern method to check that a specific field in the form
+// matches a regular expression. If the check fails then add the
+// appropriate message to the form errors.
+func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if !pattern.MatchString(value) {
+		f.Errors.Add(field, "This field is invalid")
+	}
+}
+
 // Implement a PermittedValues method to check that a specific field in the form
 // matches one of a set of specific permitted values. If the check fails
 // then add the appropriate message to the form errors.
@@ -0,0 +1,361 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration files live in one directory per database driver and are named
+// NNNN_description.up.sql / NNNN_description.down.sql, for example
+//
+//	migrations/postgres/0001_create_snippets.up.sql
+//	migrations/postgres/0001_create_snippets.down.sql
+//
+// The numeric prefix is the version recorded in the schema_migrations table.
+var fileRX = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration describes a single numbered schema change and the paths of its
+// up and down SQL files. Down is empty if the migration is irreversible.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the migrations found in Dir to DB. Driver selects the SQL
+// dialect used for bookkeeping and locking ("postgres" or "sqlite3").
+type Migrator struct {
+	DB     *sql.DB
+	Driver string
+	Dir    string
+}
+
+// dialect holds the per-driver SQL the migrator needs.
+type dialect struct {
+	createTable string
+	lock        string
+	unlock      string
+}
+
+// lockID is the key used with pg_advisory_lock so that two instances started
+// at the same time don't both try to apply the same migrations.
+const lockID = 7269524
+
+var dialects = map[string]dialect{
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+		lock:   fmt.Sprintf("SELECT pg_advisory_lock(%d)", lockID),
+		unlock: fmt.Sprintf("SELECT pg_advisory_unlock(%d)", lockID),
+	},
+	// SQLite has no advisory locks, but it only ever allows one writer and
+	// the bookkeeping insert shares the migration's transaction, so a racing
+	// instance hits the primary key and rolls back rather than applying twice.
+	"sqlite3": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+	},
+}
+
+// Load reads and pairs up the migration files in dir, sorted by version.
+func Load(dir string) ([]*Migration, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, f := range files {
+		match := fileRX.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d used by both %q and %q", version, mg.Name, match[2])
+		}
+
+		// 1_x.up.sql and 0001_x.up.sql are the same version.
+		path := filepath.Join(dir, f.Name())
+		target := &mg.Up
+		if match[3] == "down" {
+			target = &mg.Down
+		}
+		if *target != "" {
+			return nil, fmt.Errorf("migrate: version %d has two %s files, %s and %s", version, match[3], filepath.Base(*target), f.Name())
+		}
+		*target = path
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up file", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations in version order (all of them if n
+// is 0) and returns the ones that were applied.
+func (m *Migrator) Up(n int) ([]*Migration, error) {
+	var done []*Migration
+
+	err := m.withLock(func(conn *sql.Conn, migrations []*Migration, applied map[int]time.Time) error {
+		for _, mg := range migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+
+			err := m.apply(conn, mg, mg.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name)
+			if err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the n most recently applied migrations (1 if n is 0) and
+// returns the ones that were rolled back.
+func (m *Migrator) Down(n int) ([]*Migration, error) {
+	if n <= 0 {
+		n = 1
+	}
+
+	var done []*Migration
+
+	err := m.withLock(func(conn *sql.Conn, migrations []*Migration, applied map[int]time.Time) error {
+		byVersion := map[int]*Migration{}
+		for _, mg := range migrations {
+			byVersion[mg.Version] = mg
+		}
+
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, v := range versions {
+			if len(done) == n {
+				break
+			}
+
+			mg, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migrate: version %d is applied but has no file in %s", v, m.Dir)
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migrate: version %d (%s) has no down file", mg.Version, mg.Name)
+			}
+
+			err := m.apply(conn, mg, mg.Down, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+			if err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every migration in Dir along with whether it has been applied.
+func (m *Migrator) Status() ([]*Status, error) {
+	var statuses []*Status
+
+	err := m.withLock(func(conn *sql.Conn, migrations []*Migration, applied map[int]time.Time) error {
+		for _, mg := range migrations {
+			at, ok := applied[mg.Version]
+			statuses = append(statuses, &Status{Migration: *mg, Applied: ok, AppliedAt: at})
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock takes the migration lock on a dedicated connection, makes sure the
+// bookkeeping table exists, and hands fn the migrations on disk together with
+// the versions already applied.
+func (m *Migrator) withLock(fn func(*sql.Conn, []*Migration, map[int]time.Time) error) (err error) {
+	d, ok := dialects[m.Driver]
+	if !ok {
+		return fmt.Errorf("migrate: unsupported driver %q", m.Driver)
+	}
+
+	migrations, err := Load(m.Dir)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d.lock != "" {
+		if _, err := conn.ExecContext(ctx, d.lock); err != nil {
+			return err
+		}
+		defer func() {
+			if _, uerr := conn.ExecContext(ctx, d.unlock); uerr != nil && err == nil {
+				err = uerr
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, d.createTable); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// apply runs the SQL in path and the bookkeeping statement in a single
+// transaction, so a failing migration leaves no trace.
+func (m *Migrator) apply(conn *sql.Conn, mg *Migration, path, bookkeeping string, args ...interface{}) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migrate: %s: %w", filepath.Base(path), err)
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migrate: recording version %d: %w", mg.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+// Create writes an empty up/down pair for a new migration in dir, numbered
+// one past the highest existing version, and returns the two paths.
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migrate: a migration name is required")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	next := 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", next, name))
+	up, down := base+".up.sql", base+".down.sql"
+
+	for _, path := range []string{up, down} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return "", "", err
+		}
+		fmt.Fprintf(f, "-- %s\n", filepath.Base(path))
+		if err := f.Close(); err != nil {
+			return "", "", err
+		}
+	}
+
+	return up, down, nil
+}
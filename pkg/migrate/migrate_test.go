@@ -0,0 +1,271 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// writeFiles creates a migrations directory holding files, by name.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// describe formats mg as version_name, followed by "-" if it has no down
+// file.
+func describe(mg *Migration) string {
+	s := fmt.Sprintf("%d_%s", mg.Version, mg.Name)
+	if mg.Down == "" {
+		s += "-"
+	}
+	return s
+}
+
+func describeAll(migrations []*Migration) string {
+	s := make([]string, len(migrations))
+	for i, mg := range migrations {
+		s[i] = describe(mg)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "Sorted by version",
+			files: []string{"0002_b.up.sql", "0002_b.down.sql", "0010_c.up.sql", "0010_c.down.sql", "0001_a.up.sql", "0001_a.down.sql"},
+			want:  "1_a 2_b 10_c",
+		},
+		{
+			name:  "Other files ignored",
+			files: []string{"0001_a.up.sql", "README", "0002_b.sql", "b.up.sql", "0003-c.up.sql"},
+			want:  "1_a-",
+		},
+		{
+			name:  "No down file",
+			files: []string{"0001_a.up.sql", "0002_b.up.sql", "0002_b.down.sql"},
+			want:  "1_a- 2_b",
+		},
+		{
+			name:  "Gap in numbering",
+			files: []string{"0001_a.up.sql", "0003_c.up.sql"},
+			want:  "1_a- 3_c-",
+		},
+		{
+			name:    "Duplicate version",
+			files:   []string{"0001_a.up.sql", "0001_b.up.sql"},
+			wantErr: "version 1 used by both",
+		},
+		{
+			name:    "Duplicate file",
+			files:   []string{"0001_a.up.sql", "1_a.up.sql"},
+			wantErr: "version 1 has two up files",
+		},
+		{
+			name:    "No up file",
+			files:   []string{"0001_a.up.sql", "0002_b.down.sql"},
+			wantErr: "version 2 (b) has no up file",
+		},
+		{
+			name: "Empty",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			for _, name := range tt.files {
+				files[name] = ""
+			}
+
+			migrations, err := Load(writeFiles(t, files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v; want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describeAll(migrations); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingDir(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("got error %v; want a not-exist error", err)
+	}
+}
+
+// newTestMigrator returns a Migrator for an empty SQLite database and the
+// migrations in files.
+func newTestMigrator(t *testing.T, files map[string]string) *Migrator {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &Migrator{DB: db, Driver: "sqlite3", Dir: writeFiles(t, files)}
+}
+
+// tables returns the names of the tables in m's database other than
+// schema_migrations, sorted.
+func tables(t *testing.T, m *Migrator) string {
+	t.Helper()
+
+	rows, err := m.DB.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(names, " ")
+}
+
+var testMigrations = map[string]string{
+	"0001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+	"0001_a.down.sql": "DROP TABLE a;",
+	"0002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+	"0002_b.down.sql": "DROP TABLE b;",
+	"0004_d.up.sql":   "CREATE TABLE d (id INTEGER);",
+	"0004_d.down.sql": "DROP TABLE d;",
+}
+
+func TestUpDown(t *testing.T) {
+	m := newTestMigrator(t, testMigrations)
+
+	steps := []struct {
+		name       string
+		run        func() ([]*Migration, error)
+		wantDone   string
+		wantTables string
+	}{
+		{"Up 1", func() ([]*Migration, error) { return m.Up(1) }, "1_a", "a"},
+		{"Up all", func() ([]*Migration, error) { return m.Up(0) }, "2_b 4_d", "a b d"},
+		{"Up nothing pending", func() ([]*Migration, error) { return m.Up(0) }, "", "a b d"},
+		{"Down default", func() ([]*Migration, error) { return m.Down(0) }, "4_d", "a b"},
+		{"Down 5", func() ([]*Migration, error) { return m.Down(5) }, "2_b 1_a", ""},
+		{"Down nothing applied", func() ([]*Migration, error) { return m.Down(1) }, "", ""},
+		{"Up again", func() ([]*Migration, error) { return m.Up(2) }, "1_a 2_b", "a b"},
+	}
+
+	for _, st := range steps {
+		done, err := st.run()
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if got := describeAll(done); got != st.wantDone {
+			t.Errorf("%s: got %q done; want %q", st.name, got, st.wantDone)
+		}
+		if got := tables(t, m); got != st.wantTables {
+			t.Errorf("%s: got tables %q; want %q", st.name, got, st.wantTables)
+		}
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied []string
+	for _, s := range statuses {
+		applied = append(applied, fmt.Sprintf("%d:%t", s.Version, s.Applied))
+	}
+	if got, want := strings.Join(applied, " "), "1:true 2:true 4:false"; got != want {
+		t.Errorf("got status %q; want %q", got, want)
+	}
+}
+
+func TestUpFailure(t *testing.T) {
+	m := newTestMigrator(t, map[string]string{
+		"0001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"0002_b.up.sql": "CREATE TABLE b (id INTEGER); NOT SQL;",
+		"0003_c.up.sql": "CREATE TABLE c (id INTEGER);",
+	})
+
+	done, err := m.Up(0)
+	if err == nil || !strings.Contains(err.Error(), "0002_b.up.sql") {
+		t.Errorf("got error %v; want one naming 0002_b.up.sql", err)
+	}
+	if got := describeAll(done); got != "1_a-" {
+		t.Errorf("got %q done; want %q", got, "1_a-")
+	}
+	// The failed migration is rolled back and the later one not attempted.
+	if got := tables(t, m); got != "a" {
+		t.Errorf("got tables %q; want %q", got, "a")
+	}
+}
+
+func TestDownErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		remove  []string
+		wantErr string
+	}{
+		{"No down file", []string{"0004_d.down.sql"}, "version 4 (d) has no down file"},
+		{"Applied but no files", []string{"0004_d.up.sql", "0004_d.down.sql"}, "version 4 is applied but has no file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMigrator(t, testMigrations)
+			if _, err := m.Up(0); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tt.remove {
+				if err := os.Remove(filepath.Join(m.Dir, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			done, err := m.Down(1)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v; want one containing %q", err, tt.wantErr)
+			}
+			if len(done) != 0 {
+				t.Errorf("got %q done; want nothing", describeAll(done))
+			}
+			if got := tables(t, m); got != "a b d" {
+				t.Errorf("got tables %q; want %q", got, "a b d")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Page size limits for SnippetStore.List.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("models: invalid pagination cursor")
+
+// ListOptions controls SnippetStore.List. The zero value lists unexpired
+// snippets newest first, DefaultPageSize at a time.
+type ListOptions struct {
+	Sort   string // "created" (default), "expires" or "title"
+	Asc    bool   // ascending instead of the default descending order
+	Limit  int    // page size, clamped to 1..MaxPageSize
+	Cursor string // SnippetPage.Next or SnippetPage.Prev from a previous call
+	Tag    string // only snippets carrying this (normalized) tag
+	Hidden bool   // include unlisted and private snippets too
+
+	// Only include snippets created in [CreatedFrom, CreatedTo). Either may
+	// be left zero for an open-ended range.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// SnippetPage is one page of List results. Next and Prev are opaque cursors
+// for the neighbouring pages and are empty at either end of the listing.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Next     string
+	Prev     string
+}
+
+// Normalize fills in defaults and clamps the page size. It is called by each
+// backend's List, so callers only need it to see the effective options.
+func (o *ListOptions) Normalize() {
+	switch o.Sort {
+	case "created", "expires", "title":
+	default:
+		o.Sort = "created"
+	}
+	if o.Limit < 1 {
+		o.Limit = DefaultPageSize
+	}
+	if o.Limit > MaxPageSize {
+		o.Limit = MaxPageSize
+	}
+}
+
+// Cursor is the decoded form of a pagination cursor: the sort key and ID of
+// the snippet at the edge of a page, plus which way to read from it. Sort
+// and Asc are recorded too so a cursor can't be replayed against a listing
+// with a different ordering.
+type Cursor struct {
+	Sort   string    `json:"s"`
+	Asc    bool      `json:"a,omitempty"`
+	Before bool      `json:"b,omitempty"` // true for a Prev cursor
+	ID     int       `json:"i"`
+	Time   time.Time `json:"t,omitempty"`
+	Title  string    `json:"n,omitempty"`
+}
+
+// NewCursor builds the cursor pointing just past s in the given listing.
+func NewCursor(opts ListOptions, s *Snippet, before bool) string {
+	c := Cursor{Sort: opts.Sort, Asc: opts.Asc, Before: before, ID: s.ID}
+	switch opts.Sort {
+	case "created":
+		c.Time = s.Created
+	case "expires":
+		c.Time = s.Expires
+	case "title":
+		c.Title = s.Title
+	}
+
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// DecodeCursor parses opts.Cursor, returning nil if there is none.
+func DecodeCursor(opts ListOptions) (*Cursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{}
+	if err := json.Unmarshal(js, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != opts.Sort || c.Asc != opts.Asc || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// NewSnippetPage turns the rows fetched for one page into a SnippetPage.
+// Backends fetch opts.Limit+1 rows in reading order (reversed when following
+// a Prev cursor) so the extra row tells us whether there is another page.
+func NewSnippetPage(opts ListOptions, c *Cursor, rows []*Snippet) *SnippetPage {
+	more := len(rows) > opts.Limit
+	if more {
+		rows = rows[:opts.Limit]
+	}
+
+	backwards := c != nil && c.Before
+	if backwards {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &SnippetPage{Snippets: rows}
+	if len(rows) == 0 {
+		return page
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+
+	// Going forwards there is a previous page whenever we started from a
+	// cursor; going backwards there is always a next page (we came from it).
+	if (backwards && more) || (!backwards && c != nil) {
+		page.Prev = NewCursor(opts, first, true)
+	}
+	if (!backwards && more) || backwards {
+		page.Next = NewCursor(opts, last, false)
+	}
+
+	return page
+}
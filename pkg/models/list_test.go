@@ -0,0 +1,226 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func encodeCursor(js string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(js))
+}
+
+func TestDecodeCursor(t *testing.T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := &Snippet{ID: 7, Title: "Title", Created: created, Expires: created.AddDate(0, 0, 7)}
+
+	byCreated := ListOptions{Sort: "created"}
+	byTitleAsc := ListOptions{Sort: "title", Asc: true}
+
+	tests := []struct {
+		name   string
+		opts   ListOptions
+		cursor string
+		want   *Cursor
+	}{
+		{
+			name: "No cursor",
+			opts: byCreated,
+		},
+		{
+			name:   "Next by created",
+			opts:   byCreated,
+			cursor: NewCursor(byCreated, s, false),
+			want:   &Cursor{Sort: "created", ID: 7, Time: created},
+		},
+		{
+			name:   "Prev by title ascending",
+			opts:   byTitleAsc,
+			cursor: NewCursor(byTitleAsc, s, true),
+			want:   &Cursor{Sort: "title", Asc: true, Before: true, ID: 7, Title: "Title"},
+		},
+		{
+			name:   "Other sort",
+			opts:   byCreated,
+			cursor: NewCursor(ListOptions{Sort: "expires"}, s, false),
+		},
+		{
+			name:   "Other direction",
+			opts:   byCreated,
+			cursor: NewCursor(ListOptions{Sort: "created", Asc: true}, s, false),
+		},
+		{
+			name:   "Not base64",
+			opts:   byCreated,
+			cursor: "not a cursor!",
+		},
+		{
+			name:   "Padded base64",
+			opts:   byCreated,
+			cursor: base64.URLEncoding.EncodeToString([]byte(`{"s":"created","i":17}`)),
+		},
+		{
+			name:   "Not JSON",
+			opts:   byCreated,
+			cursor: encodeCursor(`created:7`),
+		},
+		{
+			name:   "Truncated",
+			opts:   byCreated,
+			cursor: NewCursor(byCreated, s, false)[:20],
+		},
+		{
+			name:   "Wrong types",
+			opts:   byCreated,
+			cursor: encodeCursor(`{"s":"created","i":"7"}`),
+		},
+		{
+			name:   "Bad time",
+			opts:   byCreated,
+			cursor: encodeCursor(`{"s":"created","i":7,"t":"yesterday"}`),
+		},
+		{
+			name:   "Zero ID",
+			opts:   byCreated,
+			cursor: encodeCursor(`{"s":"created","i":0}`),
+		},
+		{
+			name:   "Negative ID",
+			opts:   byCreated,
+			cursor: encodeCursor(`{"s":"created","i":-7}`),
+		},
+		{
+			name:   "No sort",
+			opts:   byCreated,
+			cursor: encodeCursor(`{"i":7}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Cursor = tt.cursor
+
+			c, err := DecodeCursor(opts)
+
+			switch {
+			case tt.cursor == "":
+				if c != nil || err != nil {
+					t.Errorf("got %+v, %v; want nil, nil", c, err)
+				}
+			case tt.want == nil:
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("got %+v, %v; want ErrInvalidCursor", c, err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c.Sort != tt.want.Sort || c.Asc != tt.want.Asc || c.Before != tt.want.Before ||
+					c.ID != tt.want.ID || !c.Time.Equal(tt.want.Time) || c.Title != tt.want.Title {
+					t.Errorf("got %+v; want %+v", c, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ListOptions
+		want ListOptions
+	}{
+		{"Zero", ListOptions{}, ListOptions{Sort: "created", Limit: DefaultPageSize}},
+		{"Unknown sort", ListOptions{Sort: "id; DROP TABLE snippets", Limit: 5}, ListOptions{Sort: "created", Limit: 5}},
+		{"Title", ListOptions{Sort: "title", Asc: true, Limit: 1}, ListOptions{Sort: "title", Asc: true, Limit: 1}},
+		{"Negative limit", ListOptions{Sort: "expires", Limit: -1}, ListOptions{Sort: "expires", Limit: DefaultPageSize}},
+		{"Limit too large", ListOptions{Limit: MaxPageSize + 1}, ListOptions{Sort: "created", Limit: MaxPageSize}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Normalize()
+			if opts != tt.want {
+				t.Errorf("got %+v; want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+// snippetIDs returns n snippets with IDs from, from+step, ...
+func snippetIDs(from, step, n int) []*Snippet {
+	rows := make([]*Snippet, n)
+	for i := range rows {
+		rows[i] = &Snippet{ID: from + i*step}
+	}
+	return rows
+}
+
+func TestNewSnippetPage(t *testing.T) {
+	opts := ListOptions{Sort: "created", Limit: 3}
+	next := &Cursor{Sort: "created", ID: 10}
+	prev := &Cursor{Sort: "created", ID: 10, Before: true}
+
+	tests := []struct {
+		name     string
+		cursor   *Cursor
+		rows     []*Snippet // as fetched, in reading order
+		wantIDs  []int
+		wantNext int // ID in the Next cursor, 0 for none
+		wantPrev int // ID in the Prev cursor, 0 for none
+	}{
+		{"Empty", nil, nil, nil, 0, 0},
+		{"Only page", nil, snippetIDs(9, -1, 2), []int{9, 8}, 0, 0},
+		{"Exactly one page", nil, snippetIDs(9, -1, 3), []int{9, 8, 7}, 0, 0},
+		{"First of several", nil, snippetIDs(9, -1, 4), []int{9, 8, 7}, 7, 0},
+		{"Middle going forwards", next, snippetIDs(9, -1, 4), []int{9, 8, 7}, 7, 9},
+		{"Last going forwards", next, snippetIDs(9, -1, 2), []int{9, 8}, 0, 9},
+		{"Empty going forwards", next, nil, nil, 0, 0},
+		{"Middle going backwards", prev, snippetIDs(11, 1, 4), []int{13, 12, 11}, 11, 13},
+		{"First going backwards", prev, snippetIDs(11, 1, 2), []int{12, 11}, 11, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewSnippetPage(opts, tt.cursor, tt.rows)
+
+			if len(page.Snippets) != len(tt.wantIDs) {
+				t.Fatalf("got %d snippets; want %d", len(page.Snippets), len(tt.wantIDs))
+			}
+			for i, s := range page.Snippets {
+				if s.ID != tt.wantIDs[i] {
+					t.Errorf("snippet %d: got ID %d; want %d", i, s.ID, tt.wantIDs[i])
+				}
+			}
+
+			checkCursor(t, "Next", opts, page.Next, tt.wantNext, false)
+			checkCursor(t, "Prev", opts, page.Prev, tt.wantPrev, true)
+		})
+	}
+}
+
+// checkCursor checks that cursor points at the snippet with ID want, or is
+// empty if want is 0.
+func checkCursor(t *testing.T, name string, opts ListOptions, cursor string, want int, before bool) {
+	t.Helper()
+
+	if want == 0 {
+		if cursor != "" {
+			t.Errorf("%s: got a cursor; want none", name)
+		}
+		return
+	}
+
+	opts.Cursor = cursor
+	c, err := DecodeCursor(opts)
+	if err != nil || c == nil {
+		t.Errorf("%s: got %+v, %v; want a cursor", name, c, err)
+		return
+	}
+	if c.ID != want || c.Before != before {
+		t.Errorf("%s: got ID %d, before %t; want %d, %t", name, c.ID, c.Before, want, before)
+	}
+}
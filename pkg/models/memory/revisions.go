@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// addRevision records the snippet's current title and content as its next
+// revision. The caller must hold the write lock.
+func (m *SnippetModel) addRevision(s *models.Snippet, userID int) {
+	if m.revisions == nil {
+		m.revisions = map[int][]*models.Revision{}
+	}
+
+	m.lastRevisionID++
+	s.Updated = time.Now()
+
+	m.revisions[s.ID] = append(m.revisions[s.ID], &models.Revision{
+		ID:        m.lastRevisionID,
+		SnippetID: s.ID,
+		Number:    len(m.revisions[s.ID]) + 1,
+		UserID:    userID,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   s.Updated,
+	})
+}
+
+// Revisions returns the history of an unexpired snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []*models.Revision{}
+
+	s, ok := m.snippets[snippetID]
+	if !ok || !s.Expires.After(time.Now()) {
+		return revisions, nil
+	}
+
+	stored := m.revisions[snippetID]
+	for i := len(stored) - 1; i >= 0; i-- {
+		r := *stored[i]
+		revisions = append(revisions, &r)
+	}
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(snippetID, number int) (*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.snippets[snippetID]
+	if !ok || !s.Expires.After(time.Now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	stored := m.revisions[snippetID]
+	if number < 1 || number > len(stored) {
+		return nil, models.ErrNoRecord
+	}
+
+	r := *stored[number-1]
+	return &r, nil
+}
+
+// Restore copies the title and content of an earlier revision back onto the
+// snippet and records that as a new revision. Tags and expiry are left as
+// they are.
+func (m *SnippetModel) Restore(snippetID, number, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[snippetID]
+	if !ok || !s.Expires.After(time.Now()) {
+		return models.ErrNoRecord
+	}
+
+	stored := m.revisions[snippetID]
+	if number < 1 || number > len(stored) {
+		return models.ErrNoRecord
+	}
+
+	s.Title = stored[number-1].Title
+	s.Content = stored[number-1].Content
+	m.addRevision(s, userID)
+
+	return nil
+}
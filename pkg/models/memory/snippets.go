@@ -0,0 +1,411 @@
+package memory
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// SnippetModel keeps snippets in process memory. It mirrors the behaviour of
+// postgres.SnippetModel (expiry filtering, newest-first ordering and
+// models.ErrNoRecord) so the application can run without a database.
+// The zero value is ready to use.
+type SnippetModel struct {
+	mu             sync.RWMutex
+	lastID         int
+	snippets       map[int]*models.Snippet
+	lastRevisionID int
+	revisions      map[int][]*models.Revision // by snippet ID, oldest first
+}
+
+var _ models.SnippetStore = (*SnippetModel)(nil)
+
+func (m *SnippetModel) Insert(userID int, title, content, expires, visibility, password string, tags []string) (int, error) {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := m.InsertMany([]*models.NewSnippet{{UserID: userID, Title: title, Content: content, Expires: expires, Tags: tags, Visibility: visibility, PasswordHash: hash}})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// InsertMany inserts all the snippets under one lock, after checking every
+// one, so either all of them appear or none do.
+func (m *SnippetModel) InsertMany(snippets []*models.NewSnippet) ([]int, error) {
+
+	// Postgres multiplies INTERVAL '1 DAY' by the expires value, so anything
+	// that isn't a whole number of days is rejected here as well.
+	days := make([]int, len(snippets))
+	slugs := make([]string, len(snippets))
+	for i, s := range snippets {
+		var err error
+		if days[i], err = strconv.Atoi(s.Expires); err != nil {
+			return nil, err
+		}
+		if slugs[i], err = models.NewSlug(); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.snippets == nil {
+		m.snippets = map[int]*models.Snippet{}
+	}
+
+	ids := make([]int, 0, len(snippets))
+	now := time.Now()
+
+	for i, s := range snippets {
+		m.lastID++
+
+		visibility := s.Visibility
+		if visibility == "" {
+			visibility = models.VisibilityPublic
+		}
+
+		m.snippets[m.lastID] = &models.Snippet{
+			ID:           m.lastID,
+			UserID:       s.UserID,
+			Title:        s.Title,
+			Content:      s.Content,
+			Tags:         append([]string{}, s.Tags...),
+			Created:      now,
+			Expires:      now.AddDate(0, 0, days[i]),
+			Visibility:   visibility,
+			Slug:         slugs[i],
+			PasswordHash: s.PasswordHash,
+		}
+		m.addRevision(m.snippets[m.lastID], s.UserID)
+
+		ids = append(ids, m.lastID)
+	}
+
+	return ids, nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(time.Now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	// Hand out a copy so callers can't mutate the stored record.
+	return clone(s), nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, s := range m.snippets {
+		if s.Slug == slug && s.Expires.After(now) {
+			return clone(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+
+	for _, s := range m.snippets {
+		if s.Expires.After(now) && s.Visibility == models.VisibilityPublic {
+			snippets = append(snippets, clone(s))
+		}
+	}
+
+	// ORDER BY created DESC, falling back to the ID so snippets created
+	// within the same clock tick still come out in a stable order.
+	sort.Slice(snippets, func(i, j int) bool {
+		if snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+
+	if len(snippets) > 3 {
+		snippets = snippets[:3]
+	}
+
+	return snippets, nil
+}
+
+// List returns one page of unexpired snippets, paginated with the same
+// cursors as the SQL backends.
+func (m *SnippetModel) List(opts models.ListOptions) (*models.SnippetPage, error) {
+	opts.Normalize()
+
+	c, err := models.DecodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := !opts.Asc
+	if c != nil && c.Before {
+		desc = !desc
+	}
+
+	// less orders snippets by (sort key, id) ascending, like the SQL
+	// backends' row-value comparison.
+	less := func(a *models.Snippet, key interface{}, id int) bool {
+		switch opts.Sort {
+		case "title":
+			if a.Title != key.(string) {
+				return a.Title < key.(string)
+			}
+		default:
+			t := key.(time.Time)
+			at := a.Created
+			if opts.Sort == "expires" {
+				at = a.Expires
+			}
+			if !at.Equal(t) {
+				return at.Before(t)
+			}
+		}
+		return a.ID < id
+	}
+	keyOf := func(s *models.Snippet) interface{} {
+		switch opts.Sort {
+		case "title":
+			return s.Title
+		case "expires":
+			return s.Expires
+		}
+		return s.Created
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) {
+			continue
+		}
+		if !opts.Hidden && s.Visibility != models.VisibilityPublic {
+			continue
+		}
+		if !opts.CreatedFrom.IsZero() && s.Created.Before(opts.CreatedFrom) {
+			continue
+		}
+		if !opts.CreatedTo.IsZero() && !s.Created.Before(opts.CreatedTo) {
+			continue
+		}
+		if opts.Tag != "" && !hasTag(s, opts.Tag) {
+			continue
+		}
+		if c != nil {
+			var key interface{} = c.Time
+			if opts.Sort == "title" {
+				key = c.Title
+			}
+			// Keep only the rows strictly past the cursor in reading order.
+			if desc && !less(s, key, c.ID) {
+				continue
+			}
+			if !desc && (less(s, key, c.ID) || s.ID == c.ID) {
+				continue
+			}
+		}
+		snippets = append(snippets, clone(s))
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := snippets[i], snippets[j]
+		if desc {
+			a, b = b, a
+		}
+		return less(a, keyOf(b), b.ID)
+	})
+
+	if len(snippets) > opts.Limit+1 {
+		snippets = snippets[:opts.Limit+1]
+	}
+
+	return models.NewSnippetPage(opts, c, snippets), nil
+}
+
+// Search finds unexpired snippets containing every word of the query,
+// ranked by models.RankSnippets.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchPage, error) {
+	terms := models.SearchTerms(query)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+
+	if len(terms) > 0 {
+		for _, s := range m.snippets {
+			if s.Expires.After(now) && s.Visibility == models.VisibilityPublic && !s.Protected() {
+				snippets = append(snippets, clone(s))
+			}
+		}
+	}
+
+	return models.RankSnippets(snippets, terms, query, page), nil
+}
+
+// TagCounts returns every tag in use by an unexpired snippet, most used first.
+func (m *SnippetModel) TagCounts() ([]*models.TagCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	byName := map[string]*models.TagCount{}
+	counts := []*models.TagCount{}
+
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) || s.Visibility != models.VisibilityPublic {
+			continue
+		}
+		for _, t := range s.Tags {
+			tc, ok := byName[t]
+			if !ok {
+				tc = &models.TagCount{Name: t}
+				byName[t] = tc
+				counts = append(counts, tc)
+			}
+			tc.Count++
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+
+	return counts, nil
+}
+
+// Update replaces the title, content, visibility and tags of an unexpired
+// snippet, restarts its expiry clock from now (unless expires is empty) and
+// records the edit as a revision by userID.
+func (m *SnippetModel) Update(id, userID int, title, content, expires, visibility string, tags []string) error {
+	days := 0
+	if expires != "" {
+		var err error
+		if days, err = strconv.Atoi(expires); err != nil {
+			return err
+		}
+	}
+
+	slug, err := models.NewSlug()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(now) {
+		return models.ErrNoRecord
+	}
+
+	s.Title = title
+	s.Content = content
+	if expires != "" {
+		s.Expires = now.AddDate(0, 0, days)
+	}
+	s.Tags = append([]string{}, tags...)
+	if visibility != "" {
+		if s.Visibility == models.VisibilityPublic && visibility != models.VisibilityPublic {
+			s.Slug = slug
+		}
+		s.Visibility = visibility
+	}
+	m.addRevision(s, userID)
+
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(time.Now()) {
+		return models.ErrNoRecord
+	}
+
+	delete(m.snippets, id)
+	delete(m.revisions, id)
+	return nil
+}
+
+// DeleteExpired removes up to limit expired snippets, oldest expiry first.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	expired := []*models.Snippet{}
+
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) {
+			expired = append(expired, s)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].Expires.Equal(expired[j].Expires) {
+			return expired[i].ID < expired[j].ID
+		}
+		return expired[i].Expires.Before(expired[j].Expires)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		delete(m.snippets, s.ID)
+		delete(m.revisions, s.ID)
+	}
+
+	return len(expired), nil
+}
+
+// clone copies a stored snippet, including its tag slice, so callers can't
+// mutate the stored record.
+func clone(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Tags = append([]string{}, s.Tags...)
+	return &c
+}
+
+func hasTag(s *models.Snippet, tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
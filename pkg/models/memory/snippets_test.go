@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// newTestSnippets returns a SnippetModel holding one public snippet per
+// entry of created, with IDs from 1, created at created[i] and expiring at
+// expires[i].
+func newTestSnippets(t *testing.T, created, expires []time.Time) *SnippetModel {
+	t.Helper()
+
+	m := &SnippetModel{}
+	for i := range created {
+		id, err := m.Insert(1, "Title", "Content", "7", models.VisibilityPublic, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.snippets[id].Created = created[i]
+		m.snippets[id].Expires = expires[i]
+	}
+	return m
+}
+
+func TestSnippetModelGet(t *testing.T) {
+	now := time.Now()
+	m := newTestSnippets(t,
+		[]time.Time{now.Add(-time.Hour), now.Add(-48 * time.Hour)},
+		[]time.Time{now.Add(time.Hour), now.Add(-time.Minute)},
+	)
+
+	tests := []struct {
+		name    string
+		id      int
+		wantErr error
+	}{
+		{"Unexpired", 1, nil},
+		{"Expired", 2, models.ErrNoRecord},
+		{"Missing", 3, models.ErrNoRecord},
+		{"Zero ID", 0, models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.Get(tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err == nil && s.ID != tt.id {
+				t.Errorf("got snippet %d; want %d", s.ID, tt.id)
+			}
+		})
+	}
+}
+
+func TestSnippetModelGetReturnsCopy(t *testing.T) {
+	m := &SnippetModel{}
+	id, err := m.Insert(1, "Title", "Content", "7", models.VisibilityPublic, "", []string{"go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Title = "Changed"
+	s.Tags[0] = "changed"
+
+	s, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Title" || s.Tags[0] != "go" {
+		t.Errorf("stored snippet was modified through a returned copy: %q %v", s.Title, s.Tags)
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		created []time.Time
+		expires []time.Time
+		want    []int
+	}{
+		{
+			name: "Empty",
+			want: []int{},
+		},
+		{
+			name:    "Newest first",
+			created: []time.Time{now.Add(-3 * time.Minute), now.Add(-time.Minute), now.Add(-2 * time.Minute)},
+			expires: []time.Time{later, later, later},
+			want:    []int{2, 3, 1},
+		},
+		{
+			name:    "Same created time by ID",
+			created: []time.Time{now, now, now},
+			expires: []time.Time{later, later, later},
+			want:    []int{3, 2, 1},
+		},
+		{
+			name:    "Expired left out",
+			created: []time.Time{now.Add(-time.Minute), now, now.Add(-2 * time.Minute)},
+			expires: []time.Time{later, now.Add(-time.Second), later},
+			want:    []int{1, 3},
+		},
+		{
+			name:    "At most three",
+			created: []time.Time{now, now, now, now, now},
+			expires: []time.Time{later, later, later, later, later},
+			want:    []int{5, 4, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestSnippets(t, tt.created, tt.expires)
+
+			snippets, err := m.Latest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]int, len(snippets))
+			for i, s := range snippets {
+				got[i] = s.ID
+			}
+			if !equalIDs(got, tt.want) {
+				t.Errorf("got IDs %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetModelLatestVisibility(t *testing.T) {
+	m := &SnippetModel{}
+	for _, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate} {
+		if _, err := m.Insert(1, "Title", "Content", "7", visibility, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 || snippets[0].ID != 1 {
+		t.Errorf("got %d snippets; want only the public one", len(snippets))
+	}
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	m := &SnippetModel{}
+
+	id, err := m.Insert(1, "First", "One", "7", models.VisibilityPublic, "", []string{"go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Update(id, 2, "Second", "Two", "", "", []string{"db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Restore(id, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err := m.Revisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		number, userID int
+		title          string
+	}{
+		{3, 3, "First"},
+		{2, 2, "Second"},
+		{1, 1, "First"},
+	}
+	if len(revs) != len(want) {
+		t.Fatalf("got %d revisions; want %d", len(revs), len(want))
+	}
+	for i, w := range want {
+		if revs[i].Number != w.number || revs[i].UserID != w.userID || revs[i].Title != w.title {
+			t.Errorf("revision %d: got number %d by %d titled %q; want %d by %d titled %q",
+				i, revs[i].Number, revs[i].UserID, revs[i].Title, w.number, w.userID, w.title)
+		}
+	}
+
+	// Restoring brings back the title and content, not the tags.
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "First" || s.Content != "One" || len(s.Tags) != 1 || s.Tags[0] != "db" {
+		t.Errorf("got %q %q %v; want First One [db]", s.Title, s.Content, s.Tags)
+	}
+	if !s.Updated.Equal(revs[0].Created) {
+		t.Errorf("got updated %v; want the restore's time %v", s.Updated, revs[0].Created)
+	}
+
+	// Revisions are immutable, and are returned as copies.
+	revs[2].Title = "Changed"
+	rev, err := m.Revision(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Title != "First" {
+		t.Errorf("got revision 1 titled %q; want First", rev.Title)
+	}
+
+	for _, number := range []int{0, 4} {
+		if _, err := m.Revision(id, number); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("revision %d: got error %v; want %v", number, err, models.ErrNoRecord)
+		}
+		if err := m.Restore(id, number, 1); !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("restoring %d: got error %v; want %v", number, err, models.ErrNoRecord)
+		}
+	}
+
+	// An expired snippet has no history.
+	m.snippets[id].Expires = time.Now().Add(-time.Second)
+	if revs, err := m.Revisions(id); err != nil || len(revs) != 0 {
+		t.Errorf("expired snippet: got %d revisions and error %v", len(revs), err)
+	}
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// TokenModel keeps API tokens in process memory with the same semantics as
+// postgres.TokenModel. The zero value is ready to use.
+type TokenModel struct {
+	mu     sync.Mutex
+	lastID int
+	tokens map[int]*token
+}
+
+// token is a stored token together with its hash.
+type token struct {
+	models.Token
+	hash []byte
+}
+
+var _ models.TokenStore = (*TokenModel)(nil)
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	plaintext, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tokens == nil {
+		m.tokens = map[int]*token{}
+	}
+
+	m.lastID++
+	m.tokens[m.lastID] = &token{
+		Token: models.Token{
+			ID:      m.lastID,
+			UserID:  userID,
+			Name:    name,
+			Scope:   scope,
+			Created: time.Now(),
+		},
+		hash: hash,
+	}
+
+	return plaintext, nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	hash := models.HashToken(plaintext)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tokens {
+		if bytes.Equal(t.hash, hash) {
+			if now := time.Now(); t.LastUsedStale(now) {
+				t.LastUsed = now
+			}
+			c := t.Token
+			return &c, nil
+		}
+	}
+
+	return nil, models.ErrInvalidToken
+}
+
+// List returns a user's tokens, newest first.
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []*models.Token{}
+
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			c := t.Token
+			tokens = append(tokens, &c)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Revoke(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.tokens, id)
+	return nil
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestTokenModelAuthenticate(t *testing.T) {
+	m := &TokenModel{}
+
+	plaintext, err := m.Insert(1, "laptop", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := m.Authenticate(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserID != 1 || token.Scope != models.ScopeRead {
+		t.Errorf("got user %d with scope %q; want 1 with %q", token.UserID, token.Scope, models.ScopeRead)
+	}
+	first := token.LastUsed
+	if first.IsZero() {
+		t.Fatal("the first use wasn't recorded")
+	}
+
+	// A second use straight away isn't written...
+	token, err = m.Authenticate(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.LastUsed.Equal(first) {
+		t.Errorf("got last use %v; want it left at %v", token.LastUsed, first)
+	}
+
+	// ...but one after LastUsedResolution is.
+	m.tokens[token.ID].LastUsed = first.Add(-models.LastUsedResolution)
+	token, err = m.Authenticate(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.LastUsed.Before(first) {
+		t.Errorf("got last use %v; want it updated", token.LastUsed)
+	}
+
+	if _, err := m.Authenticate(plaintext + "x"); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("wrong token: got error %v; want %v", err, models.ErrInvalidToken)
+	}
+}
+
+func TestTokenModelRevoke(t *testing.T) {
+	m := &TokenModel{}
+
+	plaintext, err := m.Insert(1, "laptop", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Revoke(2, 1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("another user's token: got error %v; want %v", err, models.ErrNoRecord)
+	}
+	if _, err := m.Authenticate(plaintext); err != nil {
+		t.Errorf("after a refused revoke: %v", err)
+	}
+
+	if err := m.Revoke(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(plaintext); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("revoked token: got error %v; want %v", err, models.ErrInvalidToken)
+	}
+
+	tokens, err := m.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("got %d tokens after revoking; want 0", len(tokens))
+	}
+}
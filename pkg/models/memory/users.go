@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel keeps user accounts in process memory with the same semantics as
+// postgres.UserModel. The zero value is ready to use.
+type UserModel struct {
+	mu     sync.RWMutex
+	lastID int
+	users  map[int]*models.User
+}
+
+var _ models.UserStore = (*UserModel)(nil)
+
+func (m *UserModel) Insert(name, email, password string) error {
+
+	// Create a bcrypt hash of the plain-text password.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.users == nil {
+		m.users = map[int]*models.User{}
+	}
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.lastID++
+	m.users[m.lastID] = &models.User{
+		ID:             m.lastID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now(),
+		Active:         true,
+	}
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	var user *models.User
+	for _, u := range m.users {
+		if u.Email == email && u.Active {
+			user = u
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return user.ID, nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	// Like the SQL backends, Get never exposes the password hash.
+	c := *u
+	c.HashedPassword = nil
+	return &c, nil
+}
@@ -5,15 +5,126 @@ import (
 	"time"
 )
 
-var ErrNoRecord = errors.New("models: no matching record found")
+var (
+	ErrNoRecord = errors.New("models: no matching record found")
+	// Returned when a user tries to login with an incorrect email address
+	// or password.
+	ErrInvalidCredentials = errors.New("models: invalid credentials")
+	// Returned when a user tries to signup with an email address that's
+	// already in use.
+	ErrDuplicateEmail = errors.New("models: duplicate email")
+	// Returned when an API token doesn't exist or has been revoked.
+	ErrInvalidToken = errors.New("models: invalid token")
+)
 
 type Snippet struct {
 	ID      int
+	UserID  int // 0 for snippets created before ownership was tracked
 	Title   string
 	Content string
-	MV1     string
-	MV2     string
-	MV3     string
+	Tags    []string // normalized with NormalizeTags, sorted
 	Created time.Time
 	Expires time.Time
+	Updated time.Time // when the latest revision was written
+
+	Visibility string // VisibilityPublic, VisibilityUnlisted or VisibilityPrivate
+	Slug       string // addresses the snippet when it isn't public
+
+	PasswordHash []byte // bcrypt hash; nil unless the snippet is password-protected
+}
+
+// NewSnippet holds the arguments of one Insert, for InsertMany.
+type NewSnippet struct {
+	UserID  int
+	Title   string
+	Content string
+	Expires string // days from now
+	Tags    []string
+
+	Visibility   string // VisibilityPublic if empty
+	PasswordHash []byte // see HashPassword; nil for no password
+}
+
+// Revision is an immutable copy of a snippet's title and content, written
+// each time the snippet is created, edited or restored. Number counts up
+// from 1 within each snippet.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Number    int
+	UserID    int // the author; 0 if unknown or since deleted
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+type User struct {
+	ID             int
+	Name           string
+	Email          string
+	HashedPassword []byte
+	Created        time.Time
+	Active         bool
+	Admin          bool
+}
+
+// SnippetStore is the set of snippet operations the web application depends
+// on. Each storage backend (postgres, memory, ...) provides its own
+// SnippetModel satisfying this interface, so handlers never need to know
+// which database sits behind them.
+type SnippetStore interface {
+	// Insert stores password, if not empty, as a bcrypt hash.
+	Insert(userID int, title, content, expires, visibility, password string, tags []string) (int, error)
+	// InsertMany inserts the snippets in one transaction, all or none of
+	// them, and returns their IDs in order.
+	InsertMany(snippets []*NewSnippet) ([]int, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	// Latest, List (unless ListOptions.Hidden is set), Search and TagCounts
+	// only cover public snippets. Search also leaves out password-protected
+	// ones, since matching their content would give it away.
+	Latest() ([]*Snippet, error)
+	List(opts ListOptions) (*SnippetPage, error)
+	Search(query string, page int) (*SearchPage, error)
+	TagCounts() ([]*TagCount, error)
+	// Update leaves the expiry alone if expires is empty, and the visibility
+	// if visibility is. A snippet that stops being public gets a new slug, so
+	// any slug it was shared under before doesn't keep working. The password
+	// is never changed.
+	Update(id, userID int, title, content, expires, visibility string, tags []string) error
+	Delete(id int) error
+
+	// DeleteExpired permanently removes up to limit expired snippets, oldest
+	// expiry first, and reports how many it removed.
+	DeleteExpired(limit int) (int, error)
+
+	// Revisions returns a snippet's history, newest first. Revision fetches
+	// one entry by its number and Restore copies it back onto the snippet,
+	// recording the restore as a new revision by userID.
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID, number int) (*Revision, error)
+	Restore(snippetID, number, userID int) error
+}
+
+// UserStore is the set of user account operations the web application
+// depends on, implemented by each storage backend's UserModel.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Get(id int) (*User, error)
+}
+
+// TokenStore manages personal API tokens, implemented by each storage
+// backend's TokenModel.
+type TokenStore interface {
+	// Insert creates a token and returns its plain-text form, which is not
+	// stored anywhere and can't be recovered later.
+	Insert(userID int, name, scope string) (string, error)
+	// Authenticate returns the token matching plaintext and records that it
+	// has been used, at most once per LastUsedResolution, or
+	// ErrInvalidToken.
+	Authenticate(plaintext string) (*Token, error)
+	List(userID int) ([]*Token, error)
+	// Revoke deletes one of userID's tokens.
+	Revoke(userID, id int) error
 }
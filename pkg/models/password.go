@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt cost of snippet passwords, the same as for
+// account passwords.
+const passwordCost = 12
+
+// bcryptHashLen is the length of every hash bcrypt generates.
+const bcryptHashLen = 60
+
+// HashPassword returns the bcrypt hash stored for a snippet password, or nil
+// if password is empty, which means the snippet has none.
+func HashPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+}
+
+// ValidPasswordHash reports whether hash is a bcrypt hash, for checking
+// hashes that come from outside, such as imports. bcrypt.Cost only looks at
+// the prefix, so the length is checked too.
+func ValidPasswordHash(hash []byte) bool {
+	if len(hash) != bcryptHashLen {
+		return false
+	}
+	_, err := bcrypt.Cost(hash)
+	return err == nil
+}
+
+// Protected reports whether reading the snippet needs a password.
+func (s *Snippet) Protected() bool {
+	return len(s.PasswordHash) > 0
+}
+
+// CheckPassword returns nil if password unlocks the snippet, or
+// ErrInvalidCredentials if it doesn't. Snippets without a password are
+// unlocked by anything.
+func (s *Snippet) CheckPassword(password string) error {
+	if !s.Protected() {
+		return nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(s.PasswordHash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidCredentials
+	}
+	return err
+}
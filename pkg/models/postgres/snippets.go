@@ -3,6 +3,9 @@ package postgres
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/gbih/snippetbox/pkg/models"
 )
@@ -11,35 +14,179 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Insert(title, content, expires, mv1, mv2, mv3 string) (int, error) {
+var _ models.SnippetStore = (*SnippetModel)(nil)
 
-	var id int
+// snippetColumns is the select list scanned by scanSnippet. A snippet's tags
+// are aggregated into one comma-separated column; tag names can't contain
+// commas (see models.NormalizeTags), so splitting it back up is safe.
+// Every change to a snippet writes a revision, so the newest revision dates
+// the last change.
+const snippetColumns = `id, COALESCE(user_id, 0), title, content, created, expires,
+	COALESCE((SELECT string_agg(t.name, ',' ORDER BY t.name) FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = snippets.id), ''),
+	COALESCE((SELECT MAX(r.created) FROM snippet_revisions r WHERE r.snippet_id = snippets.id), created),
+	visibility, slug, password_hash`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSnippet scans a row selected with snippetColumns, followed by any
+// extra columns into extra.
+func scanSnippet(row scanner, extra ...interface{}) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var tags string
+
+	dest := append([]interface{}{&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &tags, &s.Updated, &s.Visibility, &s.Slug, &s.PasswordHash}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	s.Tags = []string{}
+	if tags != "" {
+		s.Tags = strings.Split(tags, ",")
+		sort.Strings(s.Tags)
+	}
+
+	return s, nil
+}
+
+// querySnippets runs a query selecting snippetColumns and scans every row.
+func (m *SnippetModel) querySnippets(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Insert(userID int, title, content, expires, visibility, password string, tags []string) (int, error) {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := m.InsertMany([]*models.NewSnippet{{UserID: userID, Title: title, Content: content, Expires: expires, Tags: tags, Visibility: visibility, PasswordHash: hash}})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// InsertMany inserts each snippet with its tags and first revision, all in
+// one transaction.
+func (m *SnippetModel) InsertMany(snippets []*models.NewSnippet) ([]int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
 	stmt := `INSERT INTO snippets
-	(title, content, created, expires, mv1, mv2, mv3)
+	(user_id, title, content, created, expires, search, visibility, slug, password_hash)
 	VALUES
-	($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL '1 DAY' * $3, $4, $5, $6)
+	(NULLIF($1, 0), $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL '1 DAY' * $4,
+	` + searchVector("$2", "$3") + `, COALESCE(NULLIF($5, ''), 'public'), $6, NULLIF($7, ''))
 	RETURNING id`
 
-	err := m.DB.QueryRow(stmt, title, content, expires, mv1, mv2, mv3).Scan(&id)
+	ids := make([]int, 0, len(snippets))
+
+	for _, s := range snippets {
+		var id int
 
+		slug, err := models.NewSlug()
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRow(stmt, s.UserID, s.Title, s.Content, s.Expires, s.Visibility, slug, string(s.PasswordHash)).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = setTags(tx, id, s.Tags); err != nil {
+			return nil, err
+		}
+
+		if err = addRevision(tx, id, s.UserID); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// setTags replaces the tags attached to a snippet, creating any tags that
+// don't exist yet.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = $1`, snippetID)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	return int(id), nil
+	for _, tag := range tags {
+		_, err = tx.Exec(`INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = $2`, snippetID, tag)
+		if err != nil {
+			return err
+		}
+	}
 
+	return nil
 }
 
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 
-	stmt := `SELECT id, title, content, created, expires, mv1, mv2, mv3 FROM snippets
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
 	WHERE expires > CURRENT_TIMESTAMP AND id = $1`
 
-	row := m.DB.QueryRow(stmt, id)
-	s := &models.Snippet{}
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.MV1, &s.MV2, &s.MV3)
+	return s, nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > CURRENT_TIMESTAMP AND slug = $1`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -52,31 +199,259 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 }
 
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires, mv1, mv2, mv3 FROM snippets
-	WHERE expires > CURRENT_TIMESTAMP ORDER BY created DESC LIMIT 3`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > CURRENT_TIMESTAMP AND visibility = 'public' ORDER BY created DESC LIMIT 3`
 
-	rows, err := m.DB.Query(stmt)
+	return m.querySnippets(stmt)
+}
+
+// List returns one page of unexpired snippets using keyset pagination: the
+// cursor records the sort key and id of the last row seen, so each page is a
+// single indexed range scan however deep into the listing it is.
+func (m *SnippetModel) List(opts models.ListOptions) (*models.SnippetPage, error) {
+	opts.Normalize()
+
+	c, err := models.DecodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Following a Prev cursor reads the listing backwards from the cursor;
+	// NewSnippetPage puts the rows back into display order.
+	desc := !opts.Asc
+	if c != nil && c.Before {
+		desc = !desc
+	}
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	where := []string{"expires > CURRENT_TIMESTAMP"}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !opts.Hidden {
+		where = append(where, "visibility = 'public'")
+	}
+	if !opts.CreatedFrom.IsZero() {
+		where = append(where, "created >= "+arg(opts.CreatedFrom))
+	}
+	if !opts.CreatedTo.IsZero() {
+		where = append(where, "created < "+arg(opts.CreatedTo))
+	}
+	if opts.Tag != "" {
+		where = append(where, `id IN (SELECT st.snippet_id FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE t.name = `+arg(opts.Tag)+`)`)
+	}
+	if c != nil {
+		var key interface{} = c.Time
+		if opts.Sort == "title" {
+			key = c.Title
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", opts.Sort, cmp, arg(key), arg(c.ID)))
+	}
+
+	// opts.Sort is one of a fixed set of column names after Normalize, so it
+	// is safe to splice into the statement.
+	stmt := fmt.Sprintf(`SELECT %s FROM snippets
+	WHERE %s ORDER BY %s %s, id %s LIMIT %s`,
+		snippetColumns, strings.Join(where, " AND "), opts.Sort, dir, dir, arg(opts.Limit+1))
+
+	snippets, err := m.querySnippets(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSnippetPage(opts, c, snippets), nil
+}
+
+// searchVector is the SQL expression for the snippets.search tsvector. Title
+// words are weighted above content words so they rank higher. The same
+// expression is used to backfill the column in the migration that adds it.
+func searchVector(title, content string) string {
+	return fmt.Sprintf("setweight(to_tsvector('english', %s), 'A') || setweight(to_tsvector('english', %s), 'B')", title, content)
+}
+
+// Search runs a full-text query (websearch syntax: words, "quoted phrases",
+// -exclusions, or) against the GIN-indexed search column, best match first.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	headlineOpts := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=30, MinWords=10",
+		models.HighlightStart, models.HighlightStop)
+
+	stmt := `SELECT ` + snippetColumns + `,
+	ts_rank(search, q) AS rank, ts_headline('english', content, q, $2)
+	FROM snippets, websearch_to_tsquery('english', $1) q
+	WHERE expires > CURRENT_TIMESTAMP AND visibility = 'public' AND password_hash IS NULL
+	AND search @@ q
+	ORDER BY rank DESC, id DESC
+	LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(stmt, query, headlineOpts, models.SearchPageSize+1, (page-1)*models.SearchPageSize)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	snippets := []*models.Snippet{}
+	sp := &models.SearchPage{Query: query, Page: page, Results: []*models.SearchResult{}}
 
 	for rows.Next() {
-		s := &models.Snippet{}
+		r := &models.SearchResult{}
 
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.MV1, &s.MV2, &s.MV3)
+		r.Snippet, err = scanSnippet(rows, &r.Rank, &r.Headline)
 		if err != nil {
 			return nil, err
 		}
 
-		snippets = append(snippets, s)
+		sp.Results = append(sp.Results, r)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return snippets, nil
+	if len(sp.Results) > models.SearchPageSize {
+		sp.Results = sp.Results[:models.SearchPageSize]
+		sp.More = true
+	}
+
+	return sp, nil
+}
+
+// TagCounts returns every tag in use by an unexpired snippet, most used first.
+func (m *SnippetModel) TagCounts() ([]*models.TagCount, error) {
+
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > CURRENT_TIMESTAMP AND s.visibility = 'public'
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*models.TagCount{}
+
+	for rows.Next() {
+		tc := &models.TagCount{}
+
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Update replaces the title, content, visibility and tags of an unexpired
+// snippet, restarts its expiry clock from now (unless expires is empty) and
+// records the edit as a revision by userID.
+func (m *SnippetModel) Update(id, userID int, title, content, expires, visibility string, tags []string) error {
+
+	slug, err := models.NewSlug()
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{id, title, content, visibility, slug}
+	expiry := "expires"
+	if expires != "" {
+		args = append(args, expires)
+		expiry = "CURRENT_TIMESTAMP + INTERVAL '1 DAY' * $6"
+	}
+
+	// The right-hand sides all see the row as it was, so the slug is
+	// replaced when a public snippet becomes unlisted or private.
+	stmt := `UPDATE snippets SET
+	title = $2, content = $3, expires = ` + expiry + `,
+	search = ` + searchVector("$2", "$3") + `,
+	slug = CASE WHEN visibility = 'public' AND COALESCE(NULLIF($4, ''), visibility) <> 'public'
+		THEN $5 ELSE slug END,
+	visibility = COALESCE(NULLIF($4, ''), visibility)
+	WHERE expires > CURRENT_TIMESTAMP AND id = $1`
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	if err = setTags(tx, id, tags); err != nil {
+		return err
+	}
+
+	if err = addRevision(tx, id, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes an unexpired snippet. Its snippet_tags and
+// snippet_revisions rows go with it via ON DELETE CASCADE.
+func (m *SnippetModel) Delete(id int) error {
+
+	stmt := `DELETE FROM snippets WHERE expires > CURRENT_TIMESTAMP AND id = $1`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteExpired removes up to limit expired snippets, oldest expiry first.
+// Their tags links and revisions go with them via ON DELETE CASCADE.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires <= CURRENT_TIMESTAMP
+		ORDER BY expires LIMIT $1)`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// expectOneRow turns an UPDATE or DELETE that matched nothing into
+// models.ErrNoRecord, the same error Get returns for a missing snippet.
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
 }
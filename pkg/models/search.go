@@ -0,0 +1,180 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Number of results on each page of SnippetStore.Search.
+const SearchPageSize = 10
+
+// Search headlines mark each matched term by wrapping it in these control
+// characters. They can't appear in HTML or JSON text that matters, so the
+// presentation layer can escape the headline and then swap them for markup.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// SearchResult is one ranked match. Headline is a fragment of the content
+// around the matches, with each match wrapped in HighlightStart/Stop.
+type SearchResult struct {
+	*Snippet
+	Rank     float64
+	Headline string
+}
+
+// SearchPage is one page of Search results, best match first.
+type SearchPage struct {
+	Query   string
+	Page    int
+	Results []*SearchResult
+	More    bool
+}
+
+// SearchTerms splits a free-text query into lower-cased words. It is used by
+// the backends without a native full-text index.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// RankSnippets keeps the snippets containing every term, scores them (a hit in
+// the title counts three times as much as one in the content) and returns the
+// requested page. It is the portable stand-in for Postgres' ts_rank.
+func RankSnippets(snippets []*Snippet, terms []string, query string, page int) *SearchPage {
+	if page < 1 {
+		page = 1
+	}
+
+	results := []*SearchResult{}
+
+	for _, s := range snippets {
+		title, content := strings.ToLower(s.Title), strings.ToLower(s.Content)
+		rank := 0.0
+		for _, t := range terms {
+			n := 3*strings.Count(title, t) + strings.Count(content, t)
+			if n == 0 {
+				rank = 0
+				break
+			}
+			rank += float64(n)
+		}
+		if rank == 0 {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Snippet:  s,
+			Rank:     rank / float64(len(title)+len(content)+1),
+			Headline: Headline(s.Content, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	sp := &SearchPage{Query: query, Page: page, Results: []*SearchResult{}}
+
+	start := (page - 1) * SearchPageSize
+	if start < len(results) {
+		end := start + SearchPageSize
+		if end < len(results) {
+			sp.More = true
+		} else {
+			end = len(results)
+		}
+		sp.Results = results[start:end]
+	}
+
+	return sp
+}
+
+// Headline returns a short fragment of text around the first matching term,
+// with every match highlighted.
+func Headline(text string, terms []string) string {
+	const context = 80
+
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	// Lower-casing can change the length of some runes; fall back to the
+	// start of the text rather than mis-slicing.
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	first := -1
+	for _, t := range terms {
+		if i := indexRunes(lower, []rune(t), 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+
+	start, end := 0, len(runes)
+	if first > context {
+		start = first - context
+	}
+	if end > start+2*context {
+		end = start + 2*context
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+
+	for i := start; i < end; {
+		matched := 0
+		for _, t := range terms {
+			tr := []rune(t)
+			if len(tr) > matched && hasRunesAt(lower, tr, i) {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(HighlightStart)
+			b.WriteString(string(runes[i : i+matched]))
+			b.WriteString(HighlightStop)
+			i += matched
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+
+	if end < len(runes) {
+		b.WriteString(" …")
+	}
+
+	return b.String()
+}
+
+// indexRunes returns the index of the first occurrence of sub in s at or
+// after from, or -1.
+func indexRunes(s, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if hasRunesAt(s, sub, i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasRunesAt reports whether sub occurs in s at index i.
+func hasRunesAt(s, sub []rune, i int) bool {
+	if len(sub) == 0 || i+len(sub) > len(s) {
+		return false
+	}
+	for j := range sub {
+		if s[i+j] != sub[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Frog pond", []string{"frog", "pond"}},
+		{"  splash!  ", []string{"splash"}},
+		{"pond_side 100%", []string{"pond", "side", "100"}},
+		{"Über ÉTÉ", []string{"über", "été"}},
+		{"!?", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := SearchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	long := strings.Repeat("a ", 60) + "frog " + strings.Repeat("b ", 100)
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Every match", "A frog, a Frog, a FROG.", []string{"frog"}, "A \x02frog\x03, a \x02Frog\x03, a \x02FROG\x03."},
+		{"Longest term wins", "frogs", []string{"frog", "frogs"}, "\x02frogs\x03"},
+		{"Several terms", "old pond, frog", []string{"pond", "frog"}, "old \x02pond\x03, \x02frog\x03"},
+		{"No match", "old pond", []string{"frog"}, "old pond"},
+		{"Non-ASCII", "Ünd über", []string{"über"}, "Ünd \x02über\x03"},
+		{"Long text", long, []string{"frog"}, "… " + long[40:120] + "\x02frog\x03" + long[124:200] + " …"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Headline(tt.text, tt.terms); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankSnippets(t *testing.T) {
+	snippets := []*Snippet{
+		{ID: 1, Title: "Pond", Content: "An old silent pond. A frog jumps in."},
+		{ID: 2, Title: "Frog", Content: "A frog in the pond."},
+		{ID: 3, Title: "Moon", Content: "The autumn moon."},
+		{ID: 4, Title: "Frog pond", Content: "Frog."},
+	}
+
+	sp := RankSnippets(snippets, []string{"frog", "pond"}, "Frog pond", 1)
+	var got []int
+	for _, r := range sp.Results {
+		got = append(got, r.ID)
+	}
+	if want := []int{4, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if sp.Query != "Frog pond" || sp.Page != 1 || sp.More {
+		t.Errorf("got query %q page %d more %t", sp.Query, sp.Page, sp.More)
+	}
+	if h := sp.Results[1].Headline; h != "A \x02frog\x03 in the \x02pond\x03." {
+		t.Errorf("got headline %q", h)
+	}
+
+	// Pages of SearchPageSize.
+	many := []*Snippet{}
+	for i := 1; i <= SearchPageSize+1; i++ {
+		many = append(many, &Snippet{ID: i, Title: "Frog", Content: "Frog"})
+	}
+	if sp := RankSnippets(many, []string{"frog"}, "frog", 1); len(sp.Results) != SearchPageSize || !sp.More {
+		t.Errorf("page 1: got %d results, more %t", len(sp.Results), sp.More)
+	}
+	if sp := RankSnippets(many, []string{"frog"}, "frog", 2); len(sp.Results) != 1 || sp.More || sp.Results[0].ID != 1 {
+		t.Errorf("page 2: got %d results, more %t", len(sp.Results), sp.More)
+	}
+	if sp := RankSnippets(many, []string{"frog"}, "frog", 3); len(sp.Results) != 0 {
+		t.Errorf("page 3: got %d results", len(sp.Results))
+	}
+}
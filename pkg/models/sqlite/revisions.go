@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// addRevision records the snippet's current title and content as its next
+// revision. It must run in the same transaction as the INSERT or UPDATE that
+// produced that state; SQLite allows one writer at a time, so two edits
+// can't pick the same revision number.
+func addRevision(tx *sql.Tx, snippetID, userID int) error {
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, number, user_id, title, content, created)
+	SELECT id, (SELECT COALESCE(MAX(number), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?),
+		NULLIF(?, 0), title, content, CURRENT_TIMESTAMP
+	FROM snippets WHERE id = ?`
+
+	_, err := tx.Exec(stmt, snippetID, userID, snippetID)
+	return err
+}
+
+// Revisions returns the history of an unexpired snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+
+	stmt := `SELECT r.id, r.snippet_id, r.number, COALESCE(r.user_id, 0), r.title, r.content, r.created
+	FROM snippet_revisions r JOIN snippets s ON s.id = r.snippet_id
+	WHERE s.expires > CURRENT_TIMESTAMP AND r.snippet_id = ?
+	ORDER BY r.number DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		r := &models.Revision{}
+
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.Number, &r.UserID, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(snippetID, number int) (*models.Revision, error) {
+
+	stmt := `SELECT r.id, r.snippet_id, r.number, COALESCE(r.user_id, 0), r.title, r.content, r.created
+	FROM snippet_revisions r JOIN snippets s ON s.id = r.snippet_id
+	WHERE s.expires > CURRENT_TIMESTAMP AND r.snippet_id = ? AND r.number = ?`
+
+	r := &models.Revision{}
+
+	err := m.DB.QueryRow(stmt, snippetID, number).Scan(&r.ID, &r.SnippetID, &r.Number, &r.UserID, &r.Title, &r.Content, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Restore copies the title and content of an earlier revision back onto the
+// snippet and records that as a new revision. Tags and expiry are left as
+// they are.
+func (m *SnippetModel) Restore(snippetID, number, userID int) error {
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = r.title, content = r.content
+	FROM snippet_revisions r
+	WHERE snippets.expires > CURRENT_TIMESTAMP AND snippets.id = ?
+	AND r.snippet_id = snippets.id AND r.number = ?`
+
+	result, err := tx.Exec(stmt, snippetID, number)
+	if err != nil {
+		return err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	if err = addRevision(tx, snippetID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,518 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// SnippetModel is the SQLite counterpart of postgres.SnippetModel. Timestamps
+// are stored as DATETIME text ("YYYY-MM-DD HH:MM:SS", UTC) so they compare
+// correctly as strings and are scanned back into time.Time by the driver.
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+var _ models.SnippetStore = (*SnippetModel)(nil)
+
+// snippetColumns is the select list scanned by scanSnippet. A snippet's tags
+// are aggregated into one comma-separated column; tag names can't contain
+// commas (see models.NormalizeTags), so splitting it back up is safe.
+// group_concat doesn't order its input, so scanSnippet sorts the tags.
+// Every change to a snippet writes a revision, so the newest revision dates
+// the last change. The driver only converts plain DATETIME columns to
+// time.Time, so that one comes back as text and is parsed by scanSnippet.
+// The slug column can't be declared NOT NULL after the fact in SQLite, hence
+// the COALESCE.
+const snippetColumns = `id, COALESCE(user_id, 0), title, content, created, expires,
+	COALESCE((SELECT group_concat(t.name) FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = snippets.id), ''),
+	datetime(COALESCE((SELECT MAX(r.created) FROM snippet_revisions r WHERE r.snippet_id = snippets.id), created)),
+	visibility, COALESCE(slug, ''), password_hash`
+
+// sqliteDatetime is the layout of SQLite's datetime() function.
+const sqliteDatetime = "2006-01-02 15:04:05"
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSnippet scans a row selected with snippetColumns.
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var tags, updated string
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &tags, &updated, &s.Visibility, &s.Slug, &s.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Updated, err = time.Parse(sqliteDatetime, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Tags = []string{}
+	if tags != "" {
+		s.Tags = strings.Split(tags, ",")
+		sort.Strings(s.Tags)
+	}
+
+	return s, nil
+}
+
+// querySnippets runs a query selecting snippetColumns and scans every row.
+func (m *SnippetModel) querySnippets(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) Insert(userID int, title, content, expires, visibility, password string, tags []string) (int, error) {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := m.InsertMany([]*models.NewSnippet{{UserID: userID, Title: title, Content: content, Expires: expires, Tags: tags, Visibility: visibility, PasswordHash: hash}})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// InsertMany inserts each snippet with its tags and first revision, all in
+// one transaction.
+func (m *SnippetModel) InsertMany(snippets []*models.NewSnippet) ([]int, error) {
+
+	// Postgres rejects a non-numeric interval multiplier; SQLite would silently
+	// store NULL, so check it up front.
+	days := make([]int, len(snippets))
+	for i, s := range snippets {
+		var err error
+		if days[i], err = strconv.Atoi(s.Expires); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets
+	(user_id, title, content, created, expires, visibility, slug, password_hash)
+	VALUES
+	(NULLIF(?, 0), ?, ?, CURRENT_TIMESTAMP, datetime(CURRENT_TIMESTAMP, '+' || ? || ' days'),
+	COALESCE(NULLIF(?, ''), 'public'), ?, NULLIF(?, ''))`
+
+	ids := make([]int, 0, len(snippets))
+
+	for i, s := range snippets {
+		slug, err := models.NewSlug()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := tx.Exec(stmt, s.UserID, s.Title, s.Content, days[i], s.Visibility, slug, string(s.PasswordHash))
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = setTags(tx, int(id), s.Tags); err != nil {
+			return nil, err
+		}
+
+		if err = addRevision(tx, int(id), s.UserID); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, int(id))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// setTags replaces the tags attached to a snippet, creating any tags that
+// don't exist yet.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?`, snippetID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > CURRENT_TIMESTAMP AND id = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > CURRENT_TIMESTAMP AND slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	// CURRENT_TIMESTAMP only has second resolution in SQLite, so break ties
+	// on the id to keep the newest snippet first.
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > CURRENT_TIMESTAMP AND visibility = 'public' ORDER BY created DESC, id DESC LIMIT 3`
+
+	return m.querySnippets(stmt)
+}
+
+// List returns one page of unexpired snippets using keyset pagination: the
+// cursor records the sort key and id of the last row seen, so each page is a
+// single indexed range scan however deep into the listing it is.
+func (m *SnippetModel) List(opts models.ListOptions) (*models.SnippetPage, error) {
+	opts.Normalize()
+
+	c, err := models.DecodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Following a Prev cursor reads the listing backwards from the cursor;
+	// NewSnippetPage puts the rows back into display order.
+	desc := !opts.Asc
+	if c != nil && c.Before {
+		desc = !desc
+	}
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	where := []string{"expires > CURRENT_TIMESTAMP"}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "?"
+	}
+
+	if !opts.Hidden {
+		where = append(where, "visibility = 'public'")
+	}
+	if !opts.CreatedFrom.IsZero() {
+		where = append(where, "created >= "+arg(sqliteTime(opts.CreatedFrom)))
+	}
+	if !opts.CreatedTo.IsZero() {
+		where = append(where, "created < "+arg(sqliteTime(opts.CreatedTo)))
+	}
+	if opts.Tag != "" {
+		where = append(where, `id IN (SELECT st.snippet_id FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE t.name = `+arg(opts.Tag)+`)`)
+	}
+	if c != nil {
+		var key interface{} = sqliteTime(c.Time)
+		if opts.Sort == "title" {
+			key = c.Title
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", opts.Sort, cmp, arg(key), arg(c.ID)))
+	}
+
+	// opts.Sort is one of a fixed set of column names after Normalize, so it
+	// is safe to splice into the statement.
+	stmt := fmt.Sprintf(`SELECT %s FROM snippets
+	WHERE %s ORDER BY %s %s, id %s LIMIT %s`,
+		snippetColumns, strings.Join(where, " AND "), opts.Sort, dir, dir, arg(opts.Limit+1))
+
+	snippets, err := m.querySnippets(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSnippetPage(opts, c, snippets), nil
+}
+
+// sqliteTime formats t the way CURRENT_TIMESTAMP does, so comparisons against
+// the stored DATETIME text behave.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteDatetime)
+}
+
+// Search finds snippets containing every word of the query. The bundled
+// SQLite is built without FTS5, so candidate rows are narrowed with LIKE and
+// then ranked and highlighted in Go by models.RankSnippets.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return models.RankSnippets(nil, nil, query, page), nil
+	}
+
+	where := []string{"expires > CURRENT_TIMESTAMP", "visibility = 'public'", "password_hash IS NULL"}
+	args := []interface{}{}
+	for _, t := range terms {
+		pattern := "%" + likeEscaper.Replace(t) + "%"
+		where = append(where, `(title LIKE ? ESCAPE '\' OR content LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + strings.Join(where, " AND ")
+
+	snippets, err := m.querySnippets(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.RankSnippets(snippets, terms, query, page), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// TagCounts returns every tag in use by an unexpired snippet, most used first.
+func (m *SnippetModel) TagCounts() ([]*models.TagCount, error) {
+
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > CURRENT_TIMESTAMP AND s.visibility = 'public'
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*models.TagCount{}
+
+	for rows.Next() {
+		tc := &models.TagCount{}
+
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Update replaces the title, content, visibility and tags of an unexpired
+// snippet, restarts its expiry clock from now (unless expires is empty) and
+// records the edit as a revision by userID.
+func (m *SnippetModel) Update(id, userID int, title, content, expires, visibility string, tags []string) error {
+
+	slug, err := models.NewSlug()
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{title, content, visibility, slug, visibility}
+	expiry := "expires"
+	if expires != "" {
+		days, err := strconv.Atoi(expires)
+		if err != nil {
+			return err
+		}
+		args = append(args, days)
+		expiry = "datetime(CURRENT_TIMESTAMP, '+' || ? || ' days')"
+	}
+	args = append(args, id)
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The right-hand sides all see the row as it was, so the slug is
+	// replaced when a public snippet becomes unlisted or private.
+	stmt := `UPDATE snippets SET
+	title = ?, content = ?,
+	slug = CASE WHEN visibility = 'public' AND COALESCE(NULLIF(?, ''), visibility) <> 'public'
+		THEN ? ELSE slug END,
+	visibility = COALESCE(NULLIF(?, ''), visibility),
+	expires = ` + expiry + `
+	WHERE expires > CURRENT_TIMESTAMP AND id = ?`
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	if err = setTags(tx, id, tags); err != nil {
+		return err
+	}
+
+	if err = addRevision(tx, id, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes an unexpired snippet with its tag links and revisions.
+// These are deleted explicitly because foreign key enforcement (and so ON DELETE
+// CASCADE) is opt-in per connection in SQLite.
+func (m *SnippetModel) Delete(id int) error {
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE FROM snippets WHERE expires > CURRENT_TIMESTAMP AND id = ?`
+
+	result, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired removes up to limit expired snippets, oldest expiry first,
+// together with their tag links and revisions (see Delete). The cut-off is
+// fixed up front so all three statements pick the same batch.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	batch := `SELECT id FROM snippets WHERE expires <= ? ORDER BY expires, id LIMIT ?`
+	now := sqliteTime(time.Now())
+
+	_, err = tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id IN (`+batch+`)`, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id IN (`+batch+`)`, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id IN (`+batch+`)`, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// expectOneRow turns an UPDATE or DELETE that matched nothing into
+// models.ErrNoRecord, the same error Get returns for a missing snippet.
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
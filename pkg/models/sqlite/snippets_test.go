@@ -0,0 +1,288 @@
+package sqlite
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestSnippetModelInsertGet(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	before := time.Now().UTC().Truncate(time.Second)
+	id, err := m.Insert(0, "Title", "Content", "7", "", "", []string{"go", "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Title" || s.Content != "Content" || s.UserID != 0 {
+		t.Errorf("got snippet %q %q by %d", s.Title, s.Content, s.UserID)
+	}
+	if !reflect.DeepEqual(s.Tags, []string{"db", "go"}) {
+		t.Errorf("got tags %v; want [db go]", s.Tags)
+	}
+	if s.Visibility != models.VisibilityPublic || s.Slug == "" {
+		t.Errorf("got visibility %q and slug %q; want public with a slug", s.Visibility, s.Slug)
+	}
+
+	// Timestamps come back as UTC time.Time, with second resolution.
+	if s.Created.Before(before) || s.Created.After(time.Now()) {
+		t.Errorf("got created %v; want about %v", s.Created, before)
+	}
+	if d := s.Expires.Sub(s.Created); d != 7*24*time.Hour {
+		t.Errorf("got expiry %v after creation; want 7 days", d)
+	}
+	if !s.Updated.Equal(s.Created) {
+		t.Errorf("got updated %v; want the creation time %v", s.Updated, s.Created)
+	}
+
+	bySlug, err := m.GetBySlug(s.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bySlug.ID != id {
+		t.Errorf("GetBySlug: got snippet %d; want %d", bySlug.ID, id)
+	}
+
+	if _, err := m.Insert(0, "Title", "Content", "soon", "", "", nil); err == nil {
+		t.Error("a non-numeric expiry was accepted")
+	}
+
+	exec(t, db, `UPDATE snippets SET expires = datetime('now', '-1 second') WHERE id = ?`, id)
+	if _, err := m.Get(id); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expired snippet: got error %v; want %v", err, models.ErrNoRecord)
+	}
+	if _, err := m.GetBySlug(s.Slug); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expired snippet by slug: got error %v; want %v", err, models.ErrNoRecord)
+	}
+}
+
+func TestSnippetModelDelete(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	var ids []int
+	for i := 0; i < 2; i++ {
+		id, err := m.Insert(0, "Title", "Content", "7", "", "", []string{"go", "db"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Update(id, 0, "New title", "New content", "", "", []string{"go"}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := m.Delete(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int // rows left for ids[0], ids[1]
+	}{
+		{"Snippets", `SELECT COUNT(*) FROM snippets WHERE id = ?`, []int{0, 1}},
+		{"Tag links", `SELECT COUNT(*) FROM snippet_tags WHERE snippet_id = ?`, []int{0, 1}},
+		{"Revisions", `SELECT COUNT(*) FROM snippet_revisions WHERE snippet_id = ?`, []int{0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, id := range ids {
+				if n := count(t, db, tt.query, id); n != tt.want[i] {
+					t.Errorf("snippet %d: got %d rows; want %d", id, n, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if err := m.Delete(ids[0]); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("deleting again: got error %v; want %v", err, models.ErrNoRecord)
+	}
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.Insert(0, "Title", "Content", "7", "", "", []string{"go"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(t, db, `UPDATE snippets SET expires = datetime('now', '-' || id || ' days') WHERE id IN (1, 2)`)
+
+	// Oldest expiry first, so snippet 2 goes before snippet 1.
+	n, err := m.DeleteExpired(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || count(t, db, `SELECT COUNT(*) FROM snippets WHERE id = 2`) != 0 {
+		t.Errorf("got %d deleted; want snippet 2 deleted", n)
+	}
+
+	n, err = m.DeleteExpired(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d deleted; want 1", n)
+	}
+
+	for _, table := range []string{"snippets", "snippet_tags", "snippet_revisions"} {
+		col := "snippet_id"
+		if table == "snippets" {
+			col = "id"
+		}
+		if n := count(t, db, `SELECT COUNT(*) FROM `+table+` WHERE `+col+` <> 3`); n != 0 {
+			t.Errorf("%s: got %d rows of expired snippets left", table, n)
+		}
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	snippets := []struct {
+		title, content, visibility, password string
+	}{
+		{"An old silent pond", "A frog jumps into the pond, splash! Silence again.", "", ""}, // 1
+		{"Frogs", "The pond is full of frogs. Frog, frog, frog, in the pond.", "", ""},       // 2
+		{"Autumn moonlight", "A worm digs silently into the chestnut.", "", ""},              // 3
+		{"Unlisted pond", "A frog in a hidden pond.", models.VisibilityUnlisted, ""},         // 4
+		{"Locked pond", "A frog in a locked pond.", "", "secret"},                            // 5
+		{"Discounts", "100% off every frog in the pond_side shop", "", ""},                   // 6
+		{"Percentages", "1000 frogs in the pondside shop", "", ""},                           // 7
+	}
+	for _, s := range snippets {
+		if _, err := m.Insert(0, s.title, s.content, "7", s.visibility, s.password, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"Every word must match", "frog pond", []int{2, 1, 7, 6}},
+		{"Title hits rank higher", "frogs", []int{2, 7}},
+		{"Case and punctuation", "SPLASH!", []int{1}},
+		{"Symbols split words", "pond_side", []int{7, 6}},
+		{"Words match inside words", "100%", []int{7, 6}},
+		{"No match", "toad", []int{}},
+		{"No words", "!!!", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp, err := m.Search(tt.query, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int{}
+			for _, r := range sp.Results {
+				got = append(got, r.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetModelList(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetModel{DB: db}
+
+	// Snippets 2 and 3 share a creation second, so the cursor has to break
+	// the tie on the ID.
+	created := []string{"2021-03-01 10:00:00", "2021-03-02 10:00:00", "2021-03-02 10:00:00", "2021-03-03 09:59:59", "2021-03-04 00:00:00"}
+	for i, c := range created {
+		if _, err := m.Insert(0, "Title", "Content", "7", "", "", nil); err != nil {
+			t.Fatal(err)
+		}
+		exec(t, db, `UPDATE snippets SET created = ? WHERE id = ?`, c, i+1)
+	}
+	// Snippet 6 is the newest, but only Hidden lists it.
+	if _, err := m.Insert(0, "Unlisted", "Content", "7", models.VisibilityUnlisted, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts models.ListOptions
+		want [][]int // the IDs on each page
+	}{
+		{"Newest first", models.ListOptions{Limit: 2}, [][]int{{5, 4}, {3, 2}, {1}}},
+		{"Oldest first", models.ListOptions{Limit: 2, Asc: true}, [][]int{{1, 2}, {3, 4}, {5}}},
+		{"Tie across pages", models.ListOptions{Limit: 3, Asc: true}, [][]int{{1, 2, 3}, {4, 5}}},
+		{"Created range", models.ListOptions{
+			Limit:       2,
+			CreatedFrom: time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC),
+			CreatedTo:   time.Date(2021, 3, 3, 10, 0, 0, 0, time.UTC),
+		}, [][]int{{4, 3}, {2}}},
+		{"Hidden", models.ListOptions{Limit: 4, Hidden: true}, [][]int{{6, 5, 4, 3}, {2, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			var pages [][]*models.Snippet
+
+			// Forwards with Next...
+			for i := 0; ; i++ {
+				page, err := m.List(opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, page.Snippets)
+				if i < len(tt.want) && !reflect.DeepEqual(snippetIDs(page.Snippets), tt.want[i]) {
+					t.Errorf("page %d: got %v; want %v", i, snippetIDs(page.Snippets), tt.want[i])
+				}
+				if page.Next == "" || i > len(tt.want) {
+					break
+				}
+				opts.Cursor = page.Next
+			}
+			if len(pages) != len(tt.want) {
+				t.Fatalf("got %d pages; want %d", len(pages), len(tt.want))
+			}
+
+			// ...and back again with Prev.
+			for i := len(tt.want) - 1; i > 0; i-- {
+				page, err := m.List(opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(snippetIDs(page.Snippets), tt.want[i]) {
+					t.Errorf("page %d going back: got %v; want %v", i, snippetIDs(page.Snippets), tt.want[i])
+				}
+				opts.Cursor = page.Prev
+			}
+		})
+	}
+
+	if _, err := m.List(models.ListOptions{Cursor: "garbage"}); !errors.Is(err, models.ErrInvalidCursor) {
+		t.Errorf("bad cursor: got error %v; want %v", err, models.ErrInvalidCursor)
+	}
+}
+
+// snippetIDs returns the IDs of snippets, in order.
+func snippetIDs(snippets []*models.Snippet) []int {
+	ids := []int{}
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
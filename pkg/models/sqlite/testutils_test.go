@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/gbih/snippetbox/pkg/migrate"
+)
+
+// newTestDB returns a temp-file database with every migration applied. It
+// leaves foreign key enforcement off, as SQLite does by default, so the
+// models can't lean on ON DELETE CASCADE.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m := &migrate.Migrator{DB: db, Driver: "sqlite3", Dir: "../../../migrations/sqlite3"}
+	if _, err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// exec runs a statement that has to succeed, such as test setup.
+func exec(t *testing.T, db *sql.DB, stmt string, args ...interface{}) {
+	t.Helper()
+
+	if _, err := db.Exec(stmt, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// count returns the number of rows query matches.
+func count(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
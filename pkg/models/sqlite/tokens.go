@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+var _ models.TokenStore = (*TokenModel)(nil)
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+
+	plaintext, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, scope, hash, created)
+	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	_, err = m.DB.Exec(stmt, userID, name, scope, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Authenticate looks the token up and then stamps last_used; the bundled
+// SQLite predates UPDATE ... RETURNING.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+
+	hash := models.HashToken(plaintext)
+
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM tokens WHERE hash = ?`
+
+	t := &models.Token{}
+	var lastUsed sql.NullTime
+
+	err := m.DB.QueryRow(stmt, hash).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidToken
+		} else {
+			return nil, err
+		}
+	}
+	t.LastUsed = lastUsed.Time
+
+	// Skipping the write also saves taking SQLite's database-wide write
+	// lock on most requests.
+	if now := time.Now(); t.LastUsedStale(now) {
+		_, err = m.DB.Exec(`UPDATE tokens SET last_used = CURRENT_TIMESTAMP WHERE id = ?`, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = now
+	}
+
+	return t, nil
+}
+
+// List returns a user's tokens, newest first.
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+
+	for rows.Next() {
+		t := &models.Token{}
+		var lastUsed sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Revoke(userID, id int) error {
+
+	stmt := `DELETE FROM tokens WHERE user_id = ? AND id = ?`
+
+	result, err := m.DB.Exec(stmt, userID, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+func TestTokenModelAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	m := &TokenModel{DB: db}
+
+	plaintext, err := m.Insert(1, "laptop", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastUsed := func() string {
+		t.Helper()
+
+		var s string
+		if err := db.QueryRow(`SELECT COALESCE(last_used, '') FROM tokens WHERE id = 1`).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	token, err := m.Authenticate(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserID != 1 || token.Scope != models.ScopeRead {
+		t.Errorf("got user %d with scope %q; want 1 with %q", token.UserID, token.Scope, models.ScopeRead)
+	}
+	if lastUsed() == "" {
+		t.Fatal("the first use wasn't recorded")
+	}
+
+	// A use within LastUsedResolution of the last isn't written...
+	exec(t, db, `UPDATE tokens SET last_used = datetime('now', '-30 seconds') WHERE id = 1`)
+	before := lastUsed()
+	if _, err := m.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got != before {
+		t.Errorf("got last use %q; want it left at %q", got, before)
+	}
+
+	// ...but a later one is.
+	exec(t, db, `UPDATE tokens SET last_used = datetime('now', '-2 minutes') WHERE id = 1`)
+	before = lastUsed()
+	if _, err := m.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got == before {
+		t.Errorf("got last use %q; want it updated", got)
+	}
+
+	if _, err := m.Authenticate(plaintext + "x"); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("wrong token: got error %v; want %v", err, models.ErrInvalidToken)
+	}
+
+	if err := m.Revoke(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(plaintext); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("revoked token: got error %v; want %v", err, models.ErrInvalidToken)
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits applied by NormalizeTags.
+const (
+	MaxTags      = 10
+	MaxTagLength = 32
+)
+
+var ErrInvalidTag = errors.New("models: invalid tag")
+
+// TagCount is a tag together with the number of unexpired snippets using it.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]*$`)
+
+// ParseTags splits free-form tag input on commas and whitespace and returns
+// the normalized tags, e.g. "Go, SQL  go  c++" gives [c++ go sql].
+func ParseTags(input string) ([]string, error) {
+	return NormalizeTags(strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}))
+}
+
+// NormalizeTags lower-cases and de-duplicates tags and sorts them. Tags may
+// contain letters, digits and + # . _ - and must start with a letter or digit,
+// which also guarantees they never contain the commas the SQL backends use
+// to aggregate them.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > MaxTagLength {
+			return nil, fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, t, MaxTagLength)
+		}
+		if !tagRX.MatchString(t) {
+			return nil, fmt.Errorf("%w: %q may only contain letters, digits and + # . _ -", ErrInvalidTag, t)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+
+	if len(out) > MaxTags {
+		return nil, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidTag, MaxTags)
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	many := []string{}
+	for i := 0; i <= MaxTags; i++ {
+		many = append(many, fmt.Sprint("t", i))
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"Empty", "", []string{}, false},
+		{"Separators", "go,sql\ttesting\r\nweb  ,, db", []string{"db", "go", "sql", "testing", "web"}, false},
+		{"Lower-cased and de-duplicated", "Go, GO go SQL", []string{"go", "sql"}, false},
+		{"Punctuation", "c++ c# node.js snake_case x-y", []string{"c#", "c++", "node.js", "snake_case", "x-y"}, false},
+		{"Longest tag", strings.Repeat("a", MaxTagLength), []string{strings.Repeat("a", MaxTagLength)}, false},
+		{"Too long", strings.Repeat("a", MaxTagLength+1), nil, true},
+		{"Leading punctuation", "go .hidden", nil, true},
+		{"Other characters", "go <b>", nil, true},
+		{"Non-ASCII", "café", nil, true},
+		{"Most tags", strings.Join(many[:MaxTags], " "), nil, false},
+		{"Too many", strings.Join(many, " "), nil, true},
+		{"Duplicates don't count", strings.Join(many[:MaxTags], " ") + " T0", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTag) {
+					t.Errorf("got error %v; want %v", err, ErrInvalidTag)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
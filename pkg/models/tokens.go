@@ -0,0 +1,64 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// API token scopes. A write token can also do everything a read token can.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// TokenPrefix starts every API token, so a leaked one is easy to recognise
+// (and to search for in logs or repositories).
+const TokenPrefix = "sbx_"
+
+// Token is a personal API token. Only a hash of the secret is stored; the
+// plain-text token is shown to its owner once, when it is created.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scope    string
+	Created  time.Time
+	LastUsed time.Time // zero if the token has never been used
+}
+
+// LastUsedResolution is how out of date Token.LastUsed may be. The time is
+// only written when the stored one is older, so a busy token doesn't cost a
+// database write on every request.
+const LastUsedResolution = time.Minute
+
+// LastUsedStale reports whether a use at now should be written to LastUsed.
+func (t *Token) LastUsedStale(now time.Time) bool {
+	return now.Sub(t.LastUsed) >= LastUsedResolution
+}
+
+// Allows reports whether the token grants scope.
+func (t *Token) Allows(scope string) bool {
+	return t.Scope == ScopeWrite || t.Scope == scope
+}
+
+// NewToken generates a random plain-text token and the hash to store for it.
+func NewToken() (plaintext string, hash []byte, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	plaintext = TokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return plaintext, HashToken(plaintext), nil
+}
+
+// HashToken returns the stored form of a plain-text token. The tokens are
+// long and random, so a fast unsalted hash is enough and lets the backends
+// look tokens up by hash.
+func HashToken(plaintext string) []byte {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(plaintext)))
+	return sum[:]
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAllows(t *testing.T) {
+	tests := []struct {
+		tokenScope string
+		scope      string
+		want       bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeWrite, false},
+		{ScopeWrite, ScopeRead, true},
+		{ScopeWrite, ScopeWrite, true},
+		{"", ScopeRead, false},
+		{"admin", ScopeRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tokenScope+" token for "+tt.scope, func(t *testing.T) {
+			token := &Token{Scope: tt.tokenScope}
+			if got := token.Allows(tt.scope); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenLastUsedStale(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		lastUsed time.Time
+		want     bool
+	}{
+		{"Never used", time.Time{}, true},
+		{"Just used", now, false},
+		{"Within the resolution", now.Add(-LastUsedResolution + time.Second), false},
+		{"At the resolution", now.Add(-LastUsedResolution), true},
+		{"Long ago", now.Add(-24 * time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &Token{LastUsed: tt.lastUsed}
+			if got := token.LastUsedStale(now); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	plaintext, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plaintext, TokenPrefix) {
+		t.Errorf("got token %q; want the prefix %q", plaintext, TokenPrefix)
+	}
+	if !bytes.Equal(hash, HashToken(plaintext)) {
+		t.Error("the hash doesn't match HashToken")
+	}
+	if !bytes.Equal(hash, HashToken(" "+plaintext+"\n")) {
+		t.Error("surrounding space changes the hash")
+	}
+
+	other, _, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == plaintext {
+		t.Error("two tokens are the same")
+	}
+}
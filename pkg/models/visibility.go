@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+)
+
+// Snippet visibilities. Public snippets are listed, searchable and can be
+// fetched by ID. Unlisted snippets are left out of all of that and are only
+// reachable through their slug; private ones additionally only by the users
+// allowed to change them.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities lists the valid values of Snippet.Visibility.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// NewSlug generates the random slug a snippet is addressed by when it isn't
+// public: 16 URL-safe characters carrying 96 bits of randomness, so it can't
+// be guessed, and never a number, so it can't be mistaken for an ID.
+func NewSlug() (string, error) {
+	b := make([]byte, 12)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		slug := base64.RawURLEncoding.EncodeToString(b)
+		if _, err := strconv.Atoi(slug); err != nil {
+			return slug, nil
+		}
+	}
+}
+
+// Ref is how URLs refer to the snippet: its ID if it is public, otherwise
+// its slug.
+func (s *Snippet) Ref() string {
+	if s.Visibility == VisibilityPublic {
+		return strconv.Itoa(s.ID)
+	}
+	return s.Slug
+}
+
+// Label is how pages name the snippet: "#" and its ID if it is public,
+// otherwise its slug, so they never give away the ID of a snippet that was
+// shared by link.
+func (s *Snippet) Label() string {
+	if s.Visibility == VisibilityPublic {
+		return "#" + strconv.Itoa(s.ID)
+	}
+	return s.Slug
+}
@@ -0,0 +1,169 @@
+// Package ratelimit implements per-key token buckets for limiting how often
+// clients may make requests.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly at Burst per Period.
+// The zero Limit means no limit.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Enabled reports whether l limits anything.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// interval is how long one token takes to come back.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// String formats l the way Set parses it.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// Set parses a limit such as "10/1m" (10 per minute) or "off", so a *Limit
+// can be used as a flag.Value.
+func (l *Limit) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return fmt.Errorf("invalid rate limit %q: want <requests>/<period>, e.g. 10/1m, or off", s)
+	}
+
+	burst, err := strconv.Atoi(s[:i])
+	if err != nil || burst < 1 {
+		return fmt.Errorf("invalid rate limit %q: the number of requests must be a positive integer", s)
+	}
+	period, err := time.ParseDuration(s[i+1:])
+	if err != nil || period <= 0 {
+		return fmt.Errorf("invalid rate limit %q: the period must be a positive duration such as 1m", s)
+	}
+
+	*l = Limit{Burst: burst, Period: period}
+	return nil
+}
+
+// Result describes the state of a bucket after a call to Allow, in the terms
+// of the RateLimit-* and Retry-After response headers.
+type Result struct {
+	Allowed    bool
+	Limit      int           // the bucket's capacity
+	Remaining  int           // whole tokens left
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, if not allowed
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time // when tokens was last brought up to date
+}
+
+// Limiter keeps a token bucket per key. Buckets left alone long enough to
+// have refilled are indistinguishable from new ones, so they are dropped,
+// which bounds memory by the number of keys active within one Period.
+type Limiter struct {
+	limit Limit
+	now   func() time.Time // time.Now, except in tests
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a Limiter enforcing limit, which must be enabled.
+func New(limit Limit) *Limiter {
+	return newLimiter(limit, time.Now)
+}
+
+func newLimiter(limit Limit, now func() time.Time) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		now:       now,
+		buckets:   map[string]*bucket{},
+		lastSweep: now(),
+	}
+}
+
+// Limit returns the limit l enforces.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from key's bucket if there is one.
+func (l *Limiter) Allow(key string) Result {
+	now := l.now()
+	burst := float64(l.limit.Burst)
+	interval := l.limit.interval()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.limit.Period {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+float64(now.Sub(b.last))/float64(interval))
+	b.last = now
+
+	res := Result{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((burst - b.tokens) * float64(interval))
+
+	return res
+}
+
+// Refund gives back a token Allow took from key's bucket, for callers that
+// only want to count some outcomes, e.g. failed login attempts: take a token
+// before trying, so concurrent attempts can't overshoot the limit, and refund
+// it if the attempt succeeded.
+func (l *Limiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+1)
+	}
+}
+
+// sweep drops the buckets that have had time to refill. The caller must
+// hold the lock.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.limit.Period {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
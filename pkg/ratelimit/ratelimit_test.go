@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a fake time source that only moves when told to.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(limit Limit) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+	return newLimiter(limit, c.now), c
+}
+
+func TestAllow(t *testing.T) {
+	// 3 requests at once, then one every 20 seconds.
+	limit := Limit{Burst: 3, Period: time.Minute}
+
+	type step struct {
+		advance time.Duration // before calling Allow
+		key     string
+		want    Result
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "Burst then refused",
+			steps: []step{
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 40 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 0, Reset: time.Minute}},
+				{0, "a", Result{Allowed: false, Limit: 3, Remaining: 0, Reset: time.Minute, RetryAfter: 20 * time.Second}},
+			},
+		},
+		{
+			name: "Partial refill",
+			steps: []step{
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 40 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 0, Reset: time.Minute}},
+				{5 * time.Second, "a", Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 55 * time.Second, RetryAfter: 15 * time.Second}},
+				{15 * time.Second, "a", Result{Allowed: true, Limit: 3, Remaining: 0, Reset: time.Minute}},
+			},
+		},
+		{
+			name: "Refill stops at the burst",
+			steps: []step{
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+				{time.Hour, "a", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+			},
+		},
+		{
+			name: "Keys are separate",
+			steps: []step{
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 40 * time.Second}},
+				{0, "b", Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 20 * time.Second}},
+				{0, "a", Result{Allowed: true, Limit: 3, Remaining: 0, Reset: time.Minute}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(limit)
+			for i, st := range tt.steps {
+				c.advance(st.advance)
+				if got := l.Allow(st.key); got != st.want {
+					t.Errorf("step %d: got %+v; want %+v", i, got, st.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	l, c := newTestLimiter(Limit{Burst: 2, Period: time.Minute})
+
+	l.Allow("a")
+	l.Allow("a")
+	if res := l.Allow("a"); res.Allowed {
+		t.Fatal("allowed past the burst")
+	}
+
+	// A refund gives one request back...
+	l.Refund("a")
+	if res := l.Allow("a"); !res.Allowed {
+		t.Error("not allowed after a refund")
+	}
+	if res := l.Allow("a"); res.Allowed {
+		t.Error("allowed twice after one refund")
+	}
+
+	// ...but never more than the burst.
+	c.advance(time.Hour)
+	l.Refund("a")
+	l.Refund("a")
+	for i := 0; i < 2; i++ {
+		if res := l.Allow("a"); !res.Allowed {
+			t.Errorf("request %d not allowed after refilling", i)
+		}
+	}
+	if res := l.Allow("a"); res.Allowed {
+		t.Error("refunds raised the bucket past the burst")
+	}
+
+	// Refunding a key that has no bucket does nothing.
+	l.Refund("b")
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("refund created a bucket")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newTestLimiter(Limit{Burst: 2, Period: time.Minute})
+
+	l.Allow("idle")
+	c.advance(30 * time.Second)
+	l.Allow("busy")
+
+	// No sweep until a Period has passed since the last one.
+	c.advance(29 * time.Second)
+	l.Allow("busy")
+	if len(l.buckets) != 2 {
+		t.Fatalf("got %d buckets before the sweep; want 2", len(l.buckets))
+	}
+
+	// The idle bucket has had a whole Period to refill, so it goes; the busy
+	// one was used too recently.
+	c.advance(time.Second)
+	l.Allow("new")
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("the idle bucket wasn't swept")
+	}
+	for _, key := range []string{"busy", "new"} {
+		if _, ok := l.buckets[key]; !ok {
+			t.Errorf("the %s bucket was swept", key)
+		}
+	}
+
+	// A swept key starts again with a full bucket.
+	if res := l.Allow("idle"); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("got %+v for a swept key; want a full bucket", res)
+	}
+}
+
+func TestLimitSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"10/1m", Limit{Burst: 10, Period: time.Minute}, false},
+		{" 5/30s ", Limit{Burst: 5, Period: 30 * time.Second}, false},
+		{"off", Limit{}, false},
+		{"0", Limit{}, false},
+		{"10", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"10/0s", Limit{}, true},
+		{"10/-1m", Limit{}, true},
+		{"10/minute", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var l Limit
+			err := l.Set(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v; want error %t", err, tt.wantErr)
+			}
+			if err == nil && l != tt.want {
+				t.Errorf("got %+v; want %+v", l, tt.want)
+			}
+		})
+	}
+}
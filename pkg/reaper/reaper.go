@@ -0,0 +1,128 @@
+package reaper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+)
+
+// Reaper permanently removes expired snippets and, for the SQL drivers,
+// expired rows from the sessions table. Expired snippets are already hidden
+// by every query, so this only stops the tables from growing forever.
+//
+// Work is done in batches of BatchSize rows, each in its own statement or
+// transaction, so a large backlog never holds locks for long.
+type Reaper struct {
+	Snippets  models.SnippetStore
+	DB        *sql.DB // nil for the memory driver, whose session store cleans up after itself
+	Driver    string  // "postgres" or "sqlite3"; selects the sessions dialect
+	Interval  time.Duration
+	BatchSize int
+	InfoLog   *log.Logger
+	ErrorLog  *log.Logger
+}
+
+// Default settings for the web application's -reap-interval and -reap-batch
+// flags.
+const (
+	DefaultInterval  = 10 * time.Minute
+	DefaultBatchSize = 500
+)
+
+// deleteExpiredSessions removes up to $1 expired sessions, matching the
+// expiry column each scs store writes.
+var deleteExpiredSessions = map[string]string{
+	"postgres": `DELETE FROM sessions WHERE token IN (
+		SELECT token FROM sessions WHERE expiry < CURRENT_TIMESTAMP LIMIT $1)`,
+	"sqlite3": `DELETE FROM sessions WHERE token IN (
+		SELECT token FROM sessions WHERE expiry < julianday('now') LIMIT $1)`,
+}
+
+// Run purges once straight away and then every Interval until ctx is
+// cancelled. It returns once any batch in progress has finished.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		snippets, sessions, err := r.Purge(ctx)
+		if ctx.Err() != nil {
+			// Shutting down: a batch cut short by the cancellation isn't
+			// an error worth logging.
+			return
+		}
+		if err != nil {
+			r.ErrorLog.Printf("reaper: %v", err)
+		} else if snippets > 0 || sessions > 0 {
+			r.InfoLog.Printf("reaper: deleted %d expired snippets and %d expired sessions", snippets, sessions)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes expired snippets and sessions batch by batch until none are
+// left or ctx is cancelled, and reports how many of each it deleted.
+func (r *Reaper) Purge(ctx context.Context) (snippets, sessions int, err error) {
+	batchSize := r.BatchSize
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	snippets, err = drain(ctx, batchSize, r.Snippets.DeleteExpired)
+	if err != nil {
+		return snippets, 0, fmt.Errorf("deleting expired snippets: %w", err)
+	}
+
+	if r.DB == nil {
+		return snippets, 0, nil
+	}
+
+	stmt, ok := deleteExpiredSessions[r.Driver]
+	if !ok {
+		return snippets, 0, fmt.Errorf("unsupported database driver %q", r.Driver)
+	}
+
+	sessions, err = drain(ctx, batchSize, func(limit int) (int, error) {
+		result, err := r.DB.ExecContext(ctx, stmt, limit)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		return int(n), err
+	})
+	if err != nil {
+		return snippets, sessions, fmt.Errorf("deleting expired sessions: %w", err)
+	}
+
+	return snippets, sessions, nil
+}
+
+// drain calls deleteBatch until it deletes less than a full batch, checking
+// for cancellation between batches. It returns the total deleted.
+func drain(ctx context.Context, batchSize int, deleteBatch func(limit int) (int, error)) (int, error) {
+	total := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := deleteBatch(batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
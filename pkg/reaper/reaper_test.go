@@ -0,0 +1,202 @@
+package reaper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gbih/snippetbox/pkg/models"
+	"github.com/gbih/snippetbox/pkg/models/memory"
+)
+
+// recordingStore is a memory store that records the limit of every
+// DeleteExpired call and runs afterBatch, if set, after each one.
+type recordingStore struct {
+	*memory.SnippetModel
+	limits     []int
+	afterBatch func()
+}
+
+func (s *recordingStore) DeleteExpired(limit int) (int, error) {
+	s.limits = append(s.limits, limit)
+	n, err := s.SnippetModel.DeleteExpired(limit)
+	if s.afterBatch != nil {
+		s.afterBatch()
+	}
+	return n, err
+}
+
+// newTestStore returns a store holding the given number of expired snippets,
+// with IDs from 1, followed by one that is still live.
+func newTestStore(t *testing.T, expired int) *recordingStore {
+	t.Helper()
+
+	s := &recordingStore{SnippetModel: &memory.SnippetModel{}}
+	for i := 0; i < expired; i++ {
+		if _, err := s.Insert(0, "Expired", "Content", "-1", "", "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Insert(0, "Live", "Content", "7", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTestReaper(store models.SnippetStore, batchSize int) (*Reaper, *bytes.Buffer) {
+	var logged bytes.Buffer
+	return &Reaper{
+		Snippets:  store,
+		Interval:  time.Hour,
+		BatchSize: batchSize,
+		InfoLog:   log.New(&logged, "INFO\t", 0),
+		ErrorLog:  log.New(&logged, "ERROR\t", 0),
+	}, &logged
+}
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		name       string
+		expired    int
+		batchSize  int
+		wantLimits []int
+	}{
+		{"Several batches", 5, 2, []int{2, 2, 2}},
+		{"Exact batches", 4, 2, []int{2, 2, 2}},
+		{"One batch", 3, 10, []int{10}},
+		{"Nothing expired", 0, 2, []int{2}},
+		{"Default batch size", 1, 0, []int{DefaultBatchSize}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.expired)
+			r, _ := newTestReaper(store, tt.batchSize)
+
+			snippets, sessions, err := r.Purge(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snippets != tt.expired || sessions != 0 {
+				t.Errorf("got %d snippets and %d sessions deleted; want %d and 0", snippets, sessions, tt.expired)
+			}
+			if !reflect.DeepEqual(store.limits, tt.wantLimits) {
+				t.Errorf("got batches of %v; want %v", store.limits, tt.wantLimits)
+			}
+
+			if _, err := store.Get(tt.expired + 1); err != nil {
+				t.Errorf("the live snippet: %v", err)
+			}
+		})
+	}
+}
+
+func TestPurgeCancelled(t *testing.T) {
+	t.Run("Before the first batch", func(t *testing.T) {
+		store := newTestStore(t, 5)
+		r, _ := newTestReaper(store, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		snippets, _, err := r.Purge(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v; want %v", err, context.Canceled)
+		}
+		if snippets != 0 || len(store.limits) != 0 {
+			t.Errorf("got %d deleted in %d batches; want none", snippets, len(store.limits))
+		}
+	})
+
+	t.Run("Between batches", func(t *testing.T) {
+		store := newTestStore(t, 5)
+		r, _ := newTestReaper(store, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		store.afterBatch = cancel
+
+		snippets, _, err := r.Purge(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v; want %v", err, context.Canceled)
+		}
+		if snippets != 2 || len(store.limits) != 1 {
+			t.Errorf("got %d deleted in %d batches; want the 2 of the batch in progress", snippets, len(store.limits))
+		}
+	})
+}
+
+// signalWriter is a bytes.Buffer that also signals each write on wrote.
+type signalWriter struct {
+	bytes.Buffer
+	wrote chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	n, err := w.Buffer.Write(p)
+	w.wrote <- struct{}{}
+	return n, err
+}
+
+func TestRun(t *testing.T) {
+	store := newTestStore(t, 3)
+	r, _ := newTestReaper(store, 2)
+	logged := &signalWriter{wrote: make(chan struct{}, 1)}
+	r.InfoLog = log.New(logged, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	// The first purge runs straight away. Once it has been logged Run waits
+	// for the next tick, an hour off, until it is cancelled.
+	select {
+	case <-logged.wrote:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't purge straight away")
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after its context was cancelled")
+	}
+
+	if got, want := logged.String(), "reaper: deleted 3 expired snippets and 0 expired sessions\n"; got != want {
+		t.Errorf("logged %q; want %q", got, want)
+	}
+}
+
+func TestRunCancelledMidPurge(t *testing.T) {
+	store := newTestStore(t, 5)
+	r, logged := newTestReaper(store, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.afterBatch = cancel
+
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after its context was cancelled")
+	}
+
+	// A purge cut short by shutting down isn't an error.
+	if logged.Len() != 0 {
+		t.Errorf("logged %q; want nothing", logged.String())
+	}
+}
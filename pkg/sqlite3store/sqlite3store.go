@@ -0,0 +1,96 @@
+package sqlite3store
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// SQLite3Store is a SQLite-backed scs.Store, modelled on github.com/alexedwards/scs/postgresstore.
+// It expects a sessions table with the definition:
+//
+//	CREATE TABLE sessions (
+//		token TEXT PRIMARY KEY,
+//		data BLOB NOT NULL,
+//		expiry REAL NOT NULL
+//	);
+//
+//	CREATE INDEX sessions_expiry_idx ON sessions(expiry);
+//
+// The expiry is stored as a Julian day number so it can be compared directly
+// against julianday('now').
+type SQLite3Store struct {
+	db          *sql.DB
+	stopCleanup chan bool
+}
+
+// New returns a new SQLite3Store instance, with a background cleanup goroutine
+// that runs every 5 minutes to remove expired session data.
+func New(db *sql.DB) *SQLite3Store {
+	return NewWithCleanupInterval(db, 5*time.Minute)
+}
+
+// NewWithCleanupInterval returns a new SQLite3Store instance. Setting
+// cleanupInterval to 0 prevents the cleanup goroutine from running.
+func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *SQLite3Store {
+	p := &SQLite3Store{db: db}
+	if cleanupInterval > 0 {
+		go p.startCleanup(cleanupInterval)
+	}
+	return p
+}
+
+// Find returns the data for a given session token. If the session token is
+// not found or is expired, the returned exists flag will be set to false.
+func (p *SQLite3Store) Find(token string) (b []byte, exists bool, err error) {
+	row := p.db.QueryRow("SELECT data FROM sessions WHERE token = $1 AND julianday('now') < expiry", token)
+	err = row.Scan(&b)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit adds a session token and data with the given expiry time. If the
+// session token already exists, then the data and expiry time are updated.
+func (p *SQLite3Store) Commit(token string, b []byte, expiry time.Time) error {
+	_, err := p.db.Exec("REPLACE INTO sessions (token, data, expiry) VALUES ($1, $2, julianday($3))", token, b, expiry.UTC().Format("2006-01-02T15:04:05.999"))
+	return err
+}
+
+// Delete removes a session token and corresponding data.
+func (p *SQLite3Store) Delete(token string) error {
+	_, err := p.db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	return err
+}
+
+func (p *SQLite3Store) startCleanup(interval time.Duration) {
+	p.stopCleanup = make(chan bool)
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ticker.C:
+			err := p.deleteExpired()
+			if err != nil {
+				log.Println(err)
+			}
+		case <-p.stopCleanup:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// StopCleanup terminates the background cleanup goroutine.
+func (p *SQLite3Store) StopCleanup() {
+	if p.stopCleanup != nil {
+		p.stopCleanup <- true
+	}
+}
+
+func (p *SQLite3Store) deleteExpired() error {
+	_, err := p.db.Exec("DELETE FROM sessions WHERE expiry < julianday('now')")
+	return err
+}
@@ -0,0 +1,213 @@
+// Package tlscert loads TLS certificates for the web server, reloading them
+// when the files change, and creates self-signed ones for development.
+package tlscert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often Run checks the files for changes.
+const DefaultInterval = 10 * time.Second
+
+// Reloader holds the certificate read from a certificate and key file,
+// which it rereads when either file's modification time changes. Use its
+// GetCertificate method in a tls.Config.
+type Reloader struct {
+	CertFile string
+	KeyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time // the later of the two files' modification times
+}
+
+// New returns a Reloader for the given files, which must hold a valid
+// certificate and key now.
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{CertFile: certFile, KeyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload rereads the files if they have changed since they were last read,
+// and reports whether it did. On error the previous certificate stays in
+// use.
+func (r *Reloader) Reload() (bool, error) {
+	modified, err := r.modTime()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modified.Equal(r.modified)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modified = modified
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *Reloader) modTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.CertFile, r.KeyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Run checks for changed files every interval until ctx is cancelled.
+// Certificates are usually renewed by writing both files one after the
+// other, so a failed reload is retried on the next check rather than being
+// fatal.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration, infoLog, errorLog *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			errorLog.Printf("tlscert: reloading %s: %v", r.CertFile, err)
+		} else if reloaded {
+			infoLog.Printf("tlscert: reloaded %s", r.CertFile)
+		}
+	}
+}
+
+// Names of the files SelfSigned writes.
+const (
+	SelfSignedCert = "cert.pem"
+	SelfSignedKey  = "key.pem"
+)
+
+// selfSignedLifetime is how long a development certificate is valid for.
+const selfSignedLifetime = 365 * 24 * time.Hour
+
+// SelfSigned makes sure dir holds a self-signed certificate for localhost
+// and returns the paths of the certificate and key. A certificate already
+// there is reused until it is within a week of expiring.
+func SelfSigned(dir string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, SelfSignedCert)
+	keyFile = filepath.Join(dir, SelfSignedKey)
+
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err == nil && time.Until(leaf.NotAfter) > 7*24*time.Hour {
+			return certFile, keyFile, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Snippetbox development"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedLifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+func writePEM(name, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(name, data, perm); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// Config returns a tls.Config with modern defaults serving r's certificate:
+// TLS 1.2 or later and forward-secret AEAD cipher suites only. The curves
+// are left to crypto/tls, whose defaults include the post-quantum hybrids.
+func Config(r *Reloader) *tls.Config {
+	return &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		// TLS 1.3 suites aren't configurable and are all fine.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
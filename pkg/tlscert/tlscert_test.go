@@ -0,0 +1,278 @@
+package tlscert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for name, valid until
+// notAfter, and its key, and sets both files' modification time to modified.
+func writeTestCert(t *testing.T, certFile, keyFile, name string, notAfter, modified time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, modified, certFile, keyFile)
+}
+
+func touch(t *testing.T, modified time.Time, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.Chtimes(name, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// commonName returns the name on the certificate r is serving.
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	expires := time.Now().Add(24 * time.Hour)
+	modified := time.Now().Add(-time.Hour)
+	writeTestCert(t, certFile, keyFile, "first.example.com", expires, modified)
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(t, r); got != "first.example.com" {
+		t.Fatalf("got certificate for %q; want first.example.com", got)
+	}
+
+	// Nothing changed.
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Errorf("unchanged files: got %t, %v; want false, nil", reloaded, err)
+	}
+
+	// A renewed certificate is picked up.
+	modified = modified.Add(time.Minute)
+	writeTestCert(t, certFile, keyFile, "second.example.com", expires, modified)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Errorf("renewed files: got %t, %v; want true, nil", reloaded, err)
+	}
+	if got := commonName(t, r); got != "second.example.com" {
+		t.Errorf("got certificate for %q; want second.example.com", got)
+	}
+
+	// Half-written files keep the current certificate in use, and are
+	// retried on the next call.
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modified = modified.Add(time.Minute)
+	touch(t, modified, keyFile)
+	if _, err := r.Reload(); err == nil {
+		t.Error("a broken key was accepted")
+	}
+	if got := commonName(t, r); got != "second.example.com" {
+		t.Errorf("after a failed reload: got certificate for %q; want second.example.com", got)
+	}
+
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, modified, keyFile)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Errorf("repaired files: got %t, %v; want true, nil", reloaded, err)
+	}
+
+	// A missing file is an error too.
+	if err := os.Remove(certFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Error("a missing certificate was accepted")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := New(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Error("missing files were accepted")
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "example.com", time.Now().Add(time.Hour), time.Now())
+	otherCert := filepath.Join(dir, "other.pem")
+	writeTestCert(t, otherCert, filepath.Join(dir, "other-key.pem"), "example.com", time.Now().Add(time.Hour), time.Now())
+
+	if _, err := New(otherCert, keyFile); err == nil {
+		t.Error("a certificate with someone else's key was accepted")
+	}
+}
+
+func TestSelfSigned(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+
+	certFile, keyFile, err := SelfSigned(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certFile != filepath.Join(dir, SelfSignedCert) || keyFile != filepath.Join(dir, SelfSignedKey) {
+		t.Errorf("got files %s and %s", certFile, keyFile)
+	}
+
+	fi, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got key file mode %v; want 0600", perm)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		if err := leaf.VerifyHostname(host); err != nil {
+			t.Error(err)
+		}
+	}
+	if d := time.Until(leaf.NotAfter); d < selfSignedLifetime-time.Hour {
+		t.Errorf("the certificate expires in %v; want about %v", d, selfSignedLifetime)
+	}
+
+	// A certificate that is still good is reused...
+	before, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SelfSigned(dir); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("a valid certificate was replaced")
+	}
+
+	// ...but one about to expire is replaced.
+	writeTestCert(t, certFile, keyFile, "localhost", time.Now().Add(24*time.Hour), time.Now())
+	if _, _, err := SelfSigned(dir); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(leaf.NotAfter) < 7*24*time.Hour {
+		t.Errorf("a certificate expiring at %v wasn't replaced", leaf.NotAfter)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := SelfSigned(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config(r)
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("got minimum version %x; want TLS 1.2", cfg.MinVersion)
+	}
+	if cfg.CurvePreferences != nil {
+		t.Errorf("got curves %v; want the crypto/tls defaults", cfg.CurvePreferences)
+	}
+
+	// A client trusting the certificate can connect.
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}